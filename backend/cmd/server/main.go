@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gibranda/chat-with-database/internal/api"
 	"github.com/gibranda/chat-with-database/internal/config"
 	"github.com/gibranda/chat-with-database/internal/llm"
+	"github.com/gibranda/chat-with-database/internal/profiles"
+	"github.com/gibranda/chat-with-database/internal/prompts"
+	"github.com/gibranda/chat-with-database/internal/store"
 )
 
 func main() {
@@ -23,33 +27,80 @@ func main() {
 	}
 
 	// Initialize LLM client
-	log.Printf("Connecting to Ollama at %s...\n", cfg.Ollama.Host)
-	llmClient := llm.NewOllamaClient(
-		cfg.Ollama.Host,
-		cfg.Ollama.Model,
-		cfg.Ollama.Temperature,
-		cfg.Ollama.Timeout,
-	)
-	log.Printf("✓ Ollama client initialized (model: %s)\n", cfg.Ollama.Model)
-
-	// Setup API without database connection
-	// Database will be connected when user provides credentials from UI
-	log.Println("⏳ Waiting for database connection from UI...")
-	handler := api.NewHandler(nil, nil, llmClient, cfg)
+	log.Printf("Connecting to %s LLM provider at %s...\n", cfg.LLM.Provider, cfg.LLM.Host)
+	llmClient, err := llm.NewProvider(llm.ProviderConfig{
+		Provider:       cfg.LLM.Provider,
+		Host:           cfg.LLM.Host,
+		Model:          cfg.LLM.Model,
+		EmbeddingModel: cfg.LLM.EmbeddingModel,
+		APIKey:         cfg.LLM.APIKey,
+		Temperature:    cfg.LLM.Temperature,
+		Timeout:        cfg.LLM.Timeout,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize LLM provider: %v", err)
+	}
+	log.Printf("✓ %s client initialized (model: %s)\n", cfg.LLM.Provider, cfg.LLM.Model)
+	llmClient = llm.WithCallTimeout(llmClient, time.Duration(cfg.Timeouts.LLMCall)*time.Second)
+
+	// Load connection profiles from config and the local profile store
+	profileStore, err := profiles.NewStore(cfg.Connections.StorePath, cfg.Connections.Secret, cfg.Connections.Profiles)
+	if err != nil {
+		log.Fatalf("Failed to load connection profiles: %v", err)
+	}
+	log.Printf("✓ %d connection profile(s) loaded\n", len(profileStore.List()))
+
+	// Open the query history and saved queries store
+	historyStore, err := store.Open(cfg.Storage.Path)
+	if err != nil {
+		log.Fatalf("Failed to open history storage: %v", err)
+	}
+	defer historyStore.Close()
+	log.Printf("✓ Query history stored in %s\n", cfg.Storage.Path)
+
+	// Prompt templates and messages, with overrides from agent.prompts_dir
+	promptSet, err := prompts.Load(cfg.Agent.PromptsDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	if cfg.Agent.PromptsDir != "" {
+		log.Printf("✓ Prompt templates loaded from %s\n", cfg.Agent.PromptsDir)
+	}
+
+	// Sessions connect on request from the UI, or to the default profile
+	dbStatus := "Not connected (waiting for UI input)"
+	if cfg.Connections.Default != "" {
+		if _, err := profileStore.Get(cfg.Connections.Default); err != nil {
+			log.Fatalf("Default connection profile %q: %v", cfg.Connections.Default, err)
+		}
+		dbStatus = fmt.Sprintf("Auto-connecting new sessions to profile %q", cfg.Connections.Default)
+	} else {
+		log.Println("⏳ Waiting for database connection from UI...")
+	}
+	handler := api.NewHandler(llmClient, profileStore, historyStore, promptSet, cfg)
 	router := api.SetupRouter(handler, cfg.Server.Debug)
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
 	log.Printf("\n🚀 Server starting on %s\n", addr)
-	log.Printf("🤖 LLM Model: %s\n", cfg.Ollama.Model)
-	log.Printf("📊 Database: Not connected (waiting for UI input)\n")
+	log.Printf("🤖 LLM Model: %s (%s)\n", cfg.LLM.Model, cfg.LLM.Provider)
+	log.Printf("📊 Database: %s\n", dbStatus)
 	log.Println("\nEndpoints:")
 	log.Println("  POST   /api/connection/test       - Test database connection")
 	log.Println("  POST   /api/connection/connect    - Connect to database")
 	log.Println("  POST   /api/connection/disconnect - Disconnect from database")
 	log.Println("  GET    /api/connection/status     - Get connection status")
+	log.Println("  GET    /api/connection/profiles   - List connection profiles (POST/PUT/DELETE to manage)")
 	log.Println("  POST   /api/query                 - Ask questions in natural language")
+	log.Println("  POST   /api/query/stream          - Ask questions, streamed as Server-Sent Events")
+	log.Println("  POST   /api/query/:id/cancel      - Cancel a running query")
+	log.Println("  GET    /api/history               - Search past questions (GET/DELETE /api/history/:id)")
+	log.Println("  GET    /api/saved-queries         - Saved queries (POST to save, POST /:id/run to re-run)")
+	log.Println("  GET    /api/export                - Download full results as CSV, XLSX, NDJSON or Parquet")
+	log.Println("  GET    /api/results/:id           - Page through a query's full result (/count for the total)")
 	log.Println("  GET    /api/schema                - Get database schema")
+	log.Println("  PUT    /api/schema/descriptions   - Describe a table or column (GET to list overrides)")
+	log.Println("  GET    /api/tables/:table/profile - Sampled column values: distinct counts, ranges, common values")
 	log.Println("  GET    /api/health                - Health check")
 	log.Println()
 
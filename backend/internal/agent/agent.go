@@ -1,33 +1,66 @@
 package agent
 
 import (
-    "encoding/json"
+    "context"
     "fmt"
     "regexp"
     "sort"
     "strings"
     "github.com/gibranda/chat-with-database/internal/database"
     "github.com/gibranda/chat-with-database/internal/llm"
+    "github.com/gibranda/chat-with-database/internal/prompts"
+    "github.com/gibranda/chat-with-database/internal/sqlguard"
 )
 
 type Agent struct {
-    llm                   *llm.OllamaClient
+    llm                   llm.Provider
     db                    *database.Database
     maxIterations         int
     enableQueryValidation bool
     readonlyMode          bool
     maxResults            int
     conversationHistory   []llm.ChatMessage
+	turns                 []conversationTurn
+	historyWindow         int
+	historyTokenBudget    int
+	rewriteFollowUps      bool
+	profileColumns        bool
+	classifyWithLLM       bool
+	jsonOutput            bool
+	clarifyQuestions      bool
+	pending               *pendingClarification // question awaiting the user's clarification
+	retrievalTopK         int
+	schemaMaxChars        int
+	embeddings            EmbeddingStore
+	tableIndex            *schemaIndex
 	schemaCache           *database.SchemaInfo
+	schemaSummary         string // schema text for the current question's prompts
+	prompts               *prompts.Set
+	language              string           // configured answer language
+	lang                  prompts.Language // answer language of the current question
 }
 
 type AgentResponse struct {
 	Success      bool                   `json:"success"`
 	Answer       string                 `json:"answer"`
+	Intent       string                 `json:"intent,omitempty"` // what the question was classified as
 	SQL          string                 `json:"sql,omitempty"`
 	Results      *database.QueryResult  `json:"results,omitempty"`
 	Reasoning    []ReasoningStep        `json:"reasoning"`
+	Validation   *sqlguard.Verdict      `json:"validation,omitempty"`
+	TablesUsed   []string               `json:"tables_used,omitempty"`
+	Assumptions  []string               `json:"assumptions,omitempty"` // what the model assumed when writing the SQL
+	Confidence   *float64               `json:"confidence,omitempty"`  // the model's own 0-1 estimate
 	Error        string                 `json:"error,omitempty"`
+	HistoryID    int64                  `json:"history_id,omitempty"`
+	ResultID     string                 `json:"result_id,omitempty"`
+
+	// NeedsClarification is set instead of an answer when the question was
+	// ambiguous; a question sent with Clarification.ID replies to it.
+	NeedsClarification bool           `json:"needs_clarification,omitempty"`
+	Clarification      *Clarification `json:"clarification,omitempty"`
+
+	emit func(StreamEvent)
 }
 
 type ReasoningStep struct {
@@ -38,173 +71,275 @@ type ReasoningStep struct {
 }
 
 type ToolCall struct {
+	Thought   string                 `json:"thought"`
 	Tool      string                 `json:"tool"`
 	Arguments map[string]interface{} `json:"arguments"`
 }
 
 func NewAgent(
-	llmClient *llm.OllamaClient,
+	llmClient llm.Provider,
 	db *database.Database,
 	maxIterations int,
 	enableQueryValidation bool,
 	readonlyMode bool,
 	maxResults int,
 ) *Agent {
-	return &Agent{
+	a := &Agent{
 		llm:                   llmClient,
 		db:                    db,
 		maxIterations:         maxIterations,
 		enableQueryValidation: enableQueryValidation,
+		readonlyMode:          readonlyMode,
 		maxResults:            maxResults,
 		conversationHistory:   make([]llm.ChatMessage, 0),
+		historyWindow:         defaultHistoryWindow,
+		historyTokenBudget:    defaultHistoryTokenBudget,
+		classifyWithLLM:       true,
+		jsonOutput:            true,
 	}
+	a.ConfigurePrompts(prompts.Default(), defaultLanguage)
+	return a
+}
+
+// ProcessQuery answers question. Cancelling ctx aborts in-flight LLM calls
+// and SQL statements.
+func (a *Agent) ProcessQuery(ctx context.Context, question string) (*AgentResponse, error) {
+	return a.ProcessQueryStream(ctx, question, nil)
 }
 
-func (a *Agent) ProcessQuery(question string) (*AgentResponse, error) {
+// ProcessQueryStream processes question like ProcessQuery, calling emit for
+// every reasoning step and answer token as they are produced.
+func (a *Agent) ProcessQueryStream(ctx context.Context, question string, emit func(StreamEvent)) (*AgentResponse, error) {
 	response := &AgentResponse{
 		Success:   false,
 		Reasoning: make([]ReasoningStep, 0),
+		emit:      emit,
 	}
+	a.useLanguage(ctx)
 
 	// Step 1: Get schema if not cached
 	if a.schemaCache == nil {
-		schema, err := a.db.GetFullSchema()
+		schema, err := a.db.GetFullSchema(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get schema: %w", err)
 		}
 		a.schemaCache = schema
 	}
 
-	response.Reasoning = append(response.Reasoning, ReasoningStep{
-		Step:        1,
+	a.addStep(response, ReasoningStep{
 		Action:      "analyze_schema",
 		Observation: fmt.Sprintf("Found %d tables in database", len(a.schemaCache.Tables)),
 		Thought:     "Understanding database structure",
 	})
 
-	// Check if question is about listing tables
-	questionLower := strings.ToLower(question)
-	if strings.Contains(questionLower, "show") && (strings.Contains(questionLower, "table") || strings.Contains(questionLower, "tabel")) ||
-		strings.Contains(questionLower, "list") && (strings.Contains(questionLower, "table") || strings.Contains(questionLower, "tabel")) ||
-		strings.Contains(questionLower, "what table") || strings.Contains(questionLower, "apa saja tabel") {
-		
-		// Direct response for table listing
-		var tableNames []string
-		for _, table := range a.schemaCache.Tables {
-			tableNames = append(tableNames, table.Name)
+	var standalone string
+	pending := a.takePending(ctx)
+	if pending != nil {
+		// The question answers a clarification; continue the one it was about
+		response.Intent = pending.Intent
+		standalone = a.resumeClarified(pending, question, response)
+		question = pending.Question
+	} else {
+		// Route the question by what it asks for
+		intent := a.classifyIntent(ctx, question, response)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-		
-		answer := fmt.Sprintf("Database ini memiliki %d tabel:\n\n", len(tableNames))
-		for i, name := range tableNames {
-			answer += fmt.Sprintf("%d. **%s**\n", i+1, name)
+		response.Intent = intent
+		switch intent {
+		case IntentSchema:
+			return a.answerSchemaQuestion(ctx, question, response)
+		case IntentExplain:
+			return a.explainQuery(ctx, question, response)
+		case IntentChat:
+			return a.chat(ctx, question, response)
+		case IntentWrite:
+			if a.readonlyMode {
+				response.Error = a.message("write_readonly")
+				return response, nil
+			}
+		}
+
+		// Resolve follow-ups ("now only for 2024") against earlier turns
+		standalone = a.rewriteFollowUp(ctx, question)
+		if standalone != question {
+			a.addStep(response, ReasoningStep{
+				Action:      "rewrite_question",
+				Observation: standalone,
+				Thought:     "Rewrote follow-up into a standalone question using conversation history",
+			})
+		}
+	}
+
+	// Large schemas are cut down to the tables relevant to the question
+	a.schemaSummary = a.selectSchema(ctx, standalone, response)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Ask rather than guess when the question can be read several ways
+	if pending == nil {
+		if clarification := a.clarify(ctx, standalone, response); clarification != nil {
+			return a.askClarification(question, standalone, response.Intent, clarification, response), nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
-		answer += "\nAnda bisa bertanya tentang data di tabel-tabel ini. Misalnya: 'tampilkan data dari tabel students' atau 'berapa jumlah data di tabel schools'."
-		
-		response.Success = true
-		response.Answer = answer
-		response.SQL = "-- Schema query (no SQL execution needed)"
-		
-		response.Reasoning = append(response.Reasoning, ReasoningStep{
-			Step:        2,
-			Action:      "list_tables",
-			Observation: fmt.Sprintf("Listed %d tables from schema", len(tableNames)),
-			Thought:     "Providing table list from cached schema",
-		})
-		
-		return response, nil
 	}
 
 	// Step 2: Plan the query
-	planPrompt := a.buildPlanningPrompt(question)
-	plan, err := a.llm.Generate(planPrompt, a.getSystemPrompt())
+	planPrompt := a.buildPlanningPrompt(standalone)
+	plan, err := a.llm.Generate(ctx, planPrompt, a.getSystemPrompt())
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate plan: %w", err)
 	}
 
-	response.Reasoning = append(response.Reasoning, ReasoningStep{
-		Step:        2,
+	a.addStep(response, ReasoningStep{
 		Action:      "analyze_question",
 		Observation: plan,
 		Thought:     "Planning query approach",
 	})
 
-	// Step 3: Generate SQL
-	sqlPrompt := a.buildSQLPrompt(question, plan)
-	sqlResponse, err := a.llm.Generate(sqlPrompt, a.getSystemPrompt())
+	// Ground filter values in the data of the tables involved
+	hints := a.valueHints(ctx, standalone+"\n"+plan, response)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Step 3: Explore the database with tools until the model commits to an answer
+	outcome, err := a.runToolLoop(ctx, standalone, plan, hints, response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate SQL: %w", err)
+		return nil, err
+	}
+
+	sql, results := outcome.sql, outcome.results
+	if results == nil && outcome.answer != "" {
+		// Answered from the schema alone, no query needed
+		response.Answer = outcome.answer
+		response.Success = true
+
+		a.recordTurn(question, standalone, "", nil, outcome.answer)
+
+		return response, nil
+	}
+
+	if results == nil {
+		// The loop never produced a result set; fall back to one-shot generation
+		sql, results, err = a.generateAndExecuteSQL(ctx, standalone, plan, hints, response)
+		if err != nil {
+			return nil, err
+		}
+		if results == nil {
+			return response, nil
+		}
 	}
 
-	// Extract SQL from response
-	sql := a.extractSQL(sqlResponse)
+	response.SQL = sql
+	response.Results = results
+
+	// Step 4: Generate natural language answer
+	answerPrompt := a.buildAnswerPrompt(standalone, sql, results)
+	answer, err := a.generateAnswer(ctx, response, answerPrompt)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to generate answer: %w", ctx.Err())
+		}
+		answer = a.message("answer_fallback")
+	}
+
+	response.Answer = answer
+	response.Success = true
+
+	a.addStep(response, ReasoningStep{
+		Action:      "generate_answer",
+		Observation: answer,
+		Thought:     "Formulated natural language response",
+	})
+
+	// Update conversation history
+	a.recordTurn(question, standalone, sql, results, answer)
+
+	return response, nil
+}
+
+// generateAndExecuteSQL is the one-shot plan -> SQL -> validate -> execute
+// pipeline used when the tool loop does not produce a result set. It returns
+// nil results (with response.Error set) when the query could not be run.
+func (a *Agent) generateAndExecuteSQL(ctx context.Context, question, plan, hints string, response *AgentResponse) (string, *database.QueryResult, error) {
+	// Generate SQL
+	sqlPrompt := a.buildSQLPrompt(question, plan, hints)
+	sql, err := a.generateSQL(ctx, sqlPrompt, response)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate SQL: %w", err)
+	}
 	response.SQL = sql
 
 	// Log generated SQL for debugging
 	fmt.Printf("Generated SQL: %s\n", sql)
 
-	response.Reasoning = append(response.Reasoning, ReasoningStep{
-		Step:        3,
+	a.addStep(response, ReasoningStep{
 		Action:      "generate_sql",
 		Observation: sql,
 		Thought:     "Generated SQL query",
 	})
 
-    // Step 4: Security/readonly validation
+    // Security/readonly validation
     if a.enableQueryValidation {
-        if err := a.db.ValidateSQL(sql); err != nil {
-            response.Error = fmt.Sprintf("SQL validation failed: %v", err)
-            return response, nil
+        verdict := a.db.AnalyzeSQL(sql)
+        if err := verdict.Err(); err != nil {
+            response.Error = a.message("validation_failed", err)
+            response.Validation = &verdict
+            return sql, nil, nil
         }
 
         if a.readonlyMode {
-            isReadOnly := a.db.IsReadOnlyQuery(sql)
+            isReadOnly := verdict.ReadOnly
             preview := sql
             if len(preview) > 80 {
                 preview = preview[:80]
             }
             fmt.Printf("Readonly mode check: SQL=%s..., IsReadOnly=%v\n", preview, isReadOnly)
             if !isReadOnly {
-                response.Error = "Only read-only queries are allowed in readonly mode"
-                return response, nil
+                response.Error = a.message("readonly_rejected", verdict.Reason)
+                response.Validation = &verdict
+                return sql, nil, nil
             }
         }
 
-        response.Reasoning = append(response.Reasoning, ReasoningStep{
-            Step:        4,
+        a.addStep(response, ReasoningStep{
             Action:      "validate_sql",
             Observation: "SQL validation passed",
             Thought:     "Query is safe to execute",
         })
     }
 
-	// Step 4.5: Pre-validate SQL structure with EXPLAIN to catch missing tables/columns
-	if err := a.db.ExplainQuery(sql); err != nil {
-		response.Reasoning = append(response.Reasoning, ReasoningStep{
-			Step:        len(response.Reasoning) + 1,
+	// Pre-validate SQL structure with EXPLAIN to catch missing tables/columns
+	if err := a.db.ExplainQuery(ctx, sql); err != nil {
+		if ctx.Err() != nil {
+			return sql, nil, ctx.Err()
+		}
+		a.addStep(response, ReasoningStep{
 			Action:      "pre_validate_sql",
 			Observation: fmt.Sprintf("EXPLAIN failed: %v", err),
 			Thought:     "Attempting to auto-fix SQL before execution",
 		})
 
-		fixedSQL, fixErr := a.fixQuery(sql, err.Error(), question)
+		fixedSQL, fixErr := a.fixQuery(ctx, sql, err.Error(), question, response)
 		if fixErr == nil && strings.TrimSpace(fixedSQL) != "" && fixedSQL != sql {
-			// Optional: run validation again if enabled
-			if a.enableQueryValidation {
-				if vErr := a.db.ValidateSQL(fixedSQL); vErr != nil {
-					response.Error = fmt.Sprintf("SQL validation failed after fix: %v", vErr)
-					response.SQL = fixedSQL
-					return response, nil
-				}
+			// The rewritten query gets the same policy as the first attempt
+			if vErr := a.checkSQL(fixedSQL); vErr != nil {
+				response.Error = a.message("validation_failed_after_fix", vErr)
+				response.SQL = fixedSQL
+				return sql, nil, nil
 			}
 			// Pre-validate the fixed SQL
-			if eErr := a.db.ExplainQuery(fixedSQL); eErr != nil {
-				response.Error = fmt.Sprintf("SQL pre-validation failed after fix: %v", eErr)
+			if eErr := a.db.ExplainQuery(ctx, fixedSQL); eErr != nil {
+				response.Error = a.message("prevalidation_failed_after_fix", eErr)
 				response.SQL = fixedSQL
-				return response, nil
+				return sql, nil, nil
 			}
 
-			response.Reasoning = append(response.Reasoning, ReasoningStep{
-				Step:        len(response.Reasoning) + 1,
+			a.addStep(response, ReasoningStep{
 				Action:      "fix_sql_using_explain",
 				Observation: "Query fixed and validated via EXPLAIN",
 				Thought:     "Proceeding with corrected query",
@@ -212,23 +347,31 @@ func (a *Agent) ProcessQuery(question string) (*AgentResponse, error) {
 			sql = fixedSQL
 			response.SQL = fixedSQL
 		} else {
-			response.Error = fmt.Sprintf("SQL pre-validation failed: %v. %s", err, a.generateHints(sql, err.Error()))
-			return response, nil
+			response.Error = a.message("prevalidation_failed", err, a.generateHints(sql, err.Error()))
+			return sql, nil, nil
 		}
 	}
 
-	// Step 5: Execute query
-	results, err := a.db.ExecuteQuery(sql, a.maxResults)
+	// Execute query
+	results, err := a.db.ExecuteQuery(ctx, sql, a.maxResults)
 	if err != nil {
+		if ctx.Err() != nil {
+			return sql, nil, ctx.Err()
+		}
 		// Try to fix the query
 		// attempt LLM fix
-		fixedSQL, fixErr := a.fixQuery(sql, err.Error(), question)
+		fixedSQL, fixErr := a.fixQuery(ctx, sql, err.Error(), question, response)
 		if fixErr == nil {
-			results, err = a.db.ExecuteQuery(fixedSQL, a.maxResults)
+			if vErr := a.checkSQL(fixedSQL); vErr != nil {
+				response.Error = a.message("validation_failed_after_fix", vErr)
+				response.SQL = fixedSQL
+				return sql, nil, nil
+			}
+			results, err = a.db.ExecuteQuery(ctx, fixedSQL, a.maxResults)
 			if err == nil {
+				sql = fixedSQL
 				response.SQL = fixedSQL
-				response.Reasoning = append(response.Reasoning, ReasoningStep{
-					Step:        5,
+				a.addStep(response, ReasoningStep{
 					Action:      "fix_and_retry",
 					Observation: "Query fixed and executed successfully",
 					Thought:     "Corrected SQL syntax error",
@@ -237,140 +380,47 @@ func (a *Agent) ProcessQuery(question string) (*AgentResponse, error) {
 		}
 		
 		if err != nil {
-			response.Error = fmt.Sprintf("Query execution failed: %v. %s", err, a.generateHints(sql, err.Error()))
-			return response, nil
+			if ctx.Err() != nil {
+				return sql, nil, ctx.Err()
+			}
+			response.Error = a.message("execution_failed", err, a.generateHints(sql, err.Error()))
+			return sql, nil, nil
 		}
 	}
 
-	response.Results = results
-	response.Reasoning = append(response.Reasoning, ReasoningStep{
-		Step:        len(response.Reasoning) + 1,
+	a.addStep(response, ReasoningStep{
 		Action:      "execute_query",
 		Observation: fmt.Sprintf("Retrieved %d rows", results.Count),
 		Thought:     "Query executed successfully",
 	})
 
-	// Step 6: Generate natural language answer
-	answerPrompt := a.buildAnswerPrompt(question, sql, results)
-	answer, err := a.llm.Generate(answerPrompt, a.getSystemPrompt())
-	if err != nil {
-		answer = "Query executed successfully. See results below."
-	}
-
-	response.Answer = answer
-	response.Success = true
-
-	response.Reasoning = append(response.Reasoning, ReasoningStep{
-		Step:        len(response.Reasoning) + 1,
-		Action:      "generate_answer",
-		Observation: answer,
-		Thought:     "Formulated natural language response",
-	})
-
-	// Update conversation history
-	a.conversationHistory = append(a.conversationHistory,
-		llm.ChatMessage{Role: "user", Content: question},
-		llm.ChatMessage{Role: "assistant", Content: answer},
-	)
-
-	return response, nil
+	return sql, results, nil
 }
 
 func (a *Agent) getSystemPrompt() string {
-	return `You are a friendly and helpful AI database assistant named "DB Assistant". Your role is to help users explore and understand their data through natural conversation.
-
-Your personality:
-- Friendly, approachable, and patient
-- Explain technical concepts in simple terms
-- Proactive in offering insights and suggestions
-- Always respectful and professional
-
-Key responsibilities:
-1. Understand database schemas and relationships intuitively
-2. Convert natural language questions to accurate SQL queries
-3. Provide meaningful insights from data, not just raw results
-4. Explain findings in clear, conversational language
-5. Suggest related queries that might be helpful
-
-Guidelines for SQL generation:
-- Always use proper SQL syntax for the database type
-- Consider table relationships and foreign keys carefully
-- Use JOINs when querying related tables
-- Apply appropriate filters, aggregations, and sorting
-- Limit results to reasonable amounts (default 100 rows)
-- Handle edge cases and NULL values gracefully
-- Optimize queries for performance
-
-When explaining results:
-- Start with a brief, friendly summary
-- Highlight interesting patterns or anomalies
-- Provide context and meaning to numbers
-- Suggest follow-up questions or analyses
-- Use analogies when helpful
-- Keep explanations concise but informative
-
-Response style:
-- Use conversational, natural language
-- Avoid overly technical jargon unless necessary
-- Be encouraging and positive
-- Show enthusiasm about interesting findings
-- Acknowledge limitations honestly`
+	return a.render("system", prompts.Data{})
 }
 
 func (a *Agent) buildPlanningPrompt(question string) string {
-	return fmt.Sprintf(`Given this database schema:
-
-%s
-
-User question: "%s"
-
-As a helpful database assistant, analyze this question and create a brief plan for answering it.
-
-Consider:
-1. Which ACTUAL tables from the schema contain the relevant data? (Use table names like: students, schools, teachers, etc.)
-2. What relationships exist between these tables?
-3. What aggregations, calculations, or transformations are needed?
-4. What filters or conditions should be applied?
-5. How should the results be sorted or limited?
-
-IMPORTANT: 
-- Focus on the actual data tables shown in the schema
-- Do NOT plan to use information_schema or system tables unless specifically asked
-- If asked about "tables" or "what data exists", refer to the table list in the schema
-
-Provide a clear, concise plan (2-3 sentences) that shows you understand the user's intent and which actual tables to query.`, a.schemaCache.Summary, question)
+	return a.render("plan", prompts.Data{
+		Schema:   a.schemaSummary,
+		History:  a.formatHistory(),
+		Question: question,
+	})
 }
 
-func (a *Agent) buildSQLPrompt(question, plan string) string {
-	return fmt.Sprintf(`Database schema:
-
-%s
-
-User question: "%s"
-
-Plan: %s
-
-Generate a SQL query to answer this question. 
-
-IMPORTANT RULES:
-- Return ONLY the SQL query, no explanations or markdown
-- Do NOT wrap the SQL in backticks or quotes
-- Do NOT use markdown code blocks
-- Just return the raw SQL query
-- End the query with a semicolon
-
-QUERY GUIDELINES:
-- Use the actual table names from the schema above
-- Do NOT use information_schema or system tables unless specifically asked
-- When asked about "tables" or "data", query the actual data tables (students, schools, etc.)
-- Use appropriate JOINs when querying related tables
-- Add LIMIT clause to prevent returning too many rows (default: 100)
-- Use meaningful column aliases for better readability
-
-Examples:
-- For "show tables": List the table names you see in the schema
-- For "show data": SELECT * FROM actual_table_name LIMIT 10;
-- For "count records": SELECT COUNT(*) FROM actual_table_name;`, a.schemaCache.Summary, question, plan)
+func (a *Agent) buildSQLPrompt(question, plan, hints string) string {
+	name := "sql"
+	if a.jsonOutput {
+		name = "sql_json"
+	}
+	return a.render(name, prompts.Data{
+		Schema:   a.schemaSummary,
+		Hints:    hints,
+		History:  a.formatHistory(),
+		Question: question,
+		Plan:     plan,
+	})
 }
 
 func (a *Agent) buildAnswerPrompt(question string, sql string, results *database.QueryResult) string {
@@ -380,34 +430,15 @@ func (a *Agent) buildAnswerPrompt(question string, sql string, results *database
 		previewRows = previewRows[:5]
 	}
 
-	resultsJSON, _ := json.MarshalIndent(previewRows, "", "  ")
-
-	return fmt.Sprintf(`User asked: "%s"
-
-SQL query executed:
-%s
-
-Results (%d rows total, showing first %d):
-%s
-
-As a friendly database assistant, provide a helpful and insightful response in Indonesian language.
+	resultsJSON := previewResults(results, 5)
 
-Your response should include:
-1. **Ringkasan Singkat**: Jelaskan apa yang ditunjukkan oleh data ini (2-3 kalimat)
-2. **Insight Utama**: Temuan atau pola menarik yang terlihat dari data
-3. **Angka Penting**: Statistik atau angka kunci yang perlu diperhatikan
-4. **Observasi Tambahan**: Hal menarik lainnya atau saran analisis lanjutan
-
-Gaya penulisan:
-- Gunakan bahasa yang ramah dan mudah dipahami
-- Fokus pada insight yang actionable
-- Berikan konteks pada angka-angka
-- Jika ada yang menarik atau tidak biasa, sebutkan
-- Akhiri dengan saran pertanyaan lanjutan jika relevan
-
-Contoh format:
-"Berdasarkan data yang saya temukan, [ringkasan]. Yang menarik adalah [insight]. Secara angka, [statistik]. Anda mungkin juga ingin melihat [saran]."`,
-		question, sql, results.Count, len(previewRows), string(resultsJSON))
+	return a.render("answer", prompts.Data{
+		Question:    question,
+		SQL:         sql,
+		RowCount:    rowCount(results),
+		PreviewRows: len(previewRows),
+		Results:     resultsJSON,
+	})
 }
 
 func (a *Agent) extractSQL(response string) string {
@@ -475,34 +506,42 @@ func (a *Agent) extractSQL(response string) string {
 	return sql
 }
 
-func (a *Agent) fixQuery(sql, errorMsg, originalQuestion string) (string, error) {
-	fixPrompt := fmt.Sprintf(`The following SQL query failed with an error:
-
-SQL:
-%s
-
-Error:
-%s
-
-Original question: "%s"
-
-Database schema:
-%s
+func (a *Agent) fixQuery(ctx context.Context, sql, errorMsg, originalQuestion string, response *AgentResponse) (string, error) {
+	name := "fix"
+	if a.jsonOutput {
+		name = "fix_json"
+	}
+	fixPrompt := a.render(name, prompts.Data{
+		SQL:      sql,
+		Error:    errorMsg,
+		Question: originalQuestion,
+		Schema:   a.schemaSummary,
+	})
 
-Fix the SQL query to resolve this error. Return ONLY the corrected SQL query in triple backticks.`,
-		sql, errorMsg, originalQuestion, a.schemaCache.Summary)
+	return a.generateSQL(ctx, fixPrompt, response)
+}
 
-	response, err := a.llm.Generate(fixPrompt, a.getSystemPrompt())
-	if err != nil {
-		return "", err
+// ExecuteSQL runs sql directly, without the LLM, under the same validation
+// and readonly rules as generated queries.
+func (a *Agent) ExecuteSQL(ctx context.Context, sql string) (*database.QueryResult, error) {
+	if err := a.checkSQL(sql); err != nil {
+		return nil, err
 	}
+	return a.db.ExecuteQuery(ctx, sql, a.maxResults)
+}
 
-	return a.extractSQL(response), nil
+// StreamSQL is ExecuteSQL without the max_results cap, for exports. Rows are
+// passed to onRow as they are read.
+func (a *Agent) StreamSQL(ctx context.Context, query string, onColumns func([]database.ResultColumn) error, onRow func([]interface{}) error) error {
+	if err := a.checkSQL(query); err != nil {
+		return err
+	}
+	return a.db.StreamQuery(ctx, query, onColumns, onRow)
 }
 
-func (a *Agent) GetSchema() (*database.SchemaInfo, error) {
+func (a *Agent) GetSchema(ctx context.Context) (*database.SchemaInfo, error) {
 	if a.schemaCache == nil {
-		schema, err := a.db.GetFullSchema()
+		schema, err := a.db.GetFullSchema(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -511,8 +550,8 @@ func (a *Agent) GetSchema() (*database.SchemaInfo, error) {
 	return a.schemaCache, nil
 }
 
-func (a *Agent) RefreshSchema() error {
-	schema, err := a.db.GetFullSchema()
+func (a *Agent) RefreshSchema(ctx context.Context) error {
+	schema, err := a.db.GetFullSchema(ctx)
 	if err != nil {
 		return err
 	}
@@ -522,6 +561,8 @@ func (a *Agent) RefreshSchema() error {
 
 func (a *Agent) ClearHistory() {
     a.conversationHistory = make([]llm.ChatMessage, 0)
+    a.turns = nil
+    a.pending = nil
 }
 
 // generateHints attempts to provide user-friendly suggestions based on the schema
@@ -556,13 +597,13 @@ func (a *Agent) generateHints(sql, errMsg string) string {
                 colLower := strings.ToLower(c.Name)
                 // quick filter
                 if strings.Contains(colLower, wantedLower) || strings.HasPrefix(colLower, wantedLower) || strings.HasPrefix(wantedLower, colLower) {
-                    suggestions = append(suggestions, cand{name: fmt.Sprintf("%s.%s", t.Name, c.Name), score: 0})
+                    suggestions = append(suggestions, cand{name: fmt.Sprintf("%s.%s", t.TableName, c.Name), score: 0})
                     continue
                 }
                 // fallback distance
                 d := levenshtein(wantedLower, colLower)
                 if d <= 3 { // small typo tolerance
-                    suggestions = append(suggestions, cand{name: fmt.Sprintf("%s.%s", t.Name, c.Name), score: d})
+                    suggestions = append(suggestions, cand{name: fmt.Sprintf("%s.%s", t.TableName, c.Name), score: d})
                 }
             }
         }
@@ -573,12 +614,12 @@ func (a *Agent) generateHints(sql, errMsg string) string {
         for _, t := range a.schemaCache.Tables {
             nameLower := strings.ToLower(t.Name)
             if strings.Contains(nameLower, wanted) || strings.HasPrefix(nameLower, wanted) || strings.HasPrefix(wanted, nameLower) {
-                suggestions = append(suggestions, cand{name: t.Name, score: 0})
+                suggestions = append(suggestions, cand{name: t.TableName.String(), score: 0})
                 continue
             }
             d := levenshtein(wanted, nameLower)
             if d <= 3 {
-                suggestions = append(suggestions, cand{name: t.Name, score: d})
+                suggestions = append(suggestions, cand{name: t.TableName.String(), score: d})
             }
         }
     }
@@ -595,7 +636,7 @@ func (a *Agent) generateHints(sql, errMsg string) string {
             for _, s := range suggestions {
                 names = append(names, s.name)
             }
-            return fmt.Sprintf("Hint: Kolom '%s' tidak ditemukan. Mungkin maksud Anda: %s", missing, strings.Join(names, ", "))
+            return a.message("hint_column", missing, strings.Join(names, ", "))
         }
     }
 
@@ -611,7 +652,7 @@ func (a *Agent) generateHints(sql, errMsg string) string {
             for _, s := range suggestions {
                 names = append(names, s.name)
             }
-            return fmt.Sprintf("Hint: Tabel/relasi '%s' tidak ditemukan. Mungkin maksud Anda: %s", missing, strings.Join(names, ", "))
+            return a.message("hint_table", missing, strings.Join(names, ", "))
         }
     }
 
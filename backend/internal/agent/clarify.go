@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+// maxClarificationOptions caps the suggested answers offered to the user.
+const maxClarificationOptions = 5
+
+// Clarification is a question put back to the user when theirs can be read
+// in more than one way. A reply continues the original question when it is
+// sent with the ID.
+type Clarification struct {
+	ID       string   `json:"id"`
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"` // suggested answers
+}
+
+// pendingClarification is a question waiting for the user's answer to a
+// clarification. It is dropped when the next question does not reply to it.
+type pendingClarification struct {
+	Question   string // as the user asked it
+	Standalone string // after follow-up rewriting
+	Intent     string
+	Clarification
+}
+
+var clarificationSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "ambiguous": {"type": "boolean"},
+    "reason": {"type": "string"},
+    "question": {"type": "string"},
+    "options": {"type": "array", "items": {"type": "string"}}
+  },
+  "required": ["ambiguous"]
+}`)
+
+type clarificationKey struct{}
+
+// WithClarificationReply marks the question processed with the returned
+// context as the answer to the clarification with the given ID.
+func WithClarificationReply(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clarificationKey{}, id)
+}
+
+// ConfigureClarification turns clarifying questions on or off. When on,
+// data questions the model finds ambiguous ("show the best schools") are
+// answered with a question and suggested options instead of a guess.
+func (a *Agent) ConfigureClarification(enabled bool) {
+	a.clarifyQuestions = enabled
+}
+
+// clarify asks the model whether question can only be answered by guessing
+// what the user means. It returns nil when the question is clear, the check
+// is off or the check fails.
+func (a *Agent) clarify(ctx context.Context, question string, response *AgentResponse) *Clarification {
+	if !a.clarifyQuestions {
+		return nil
+	}
+
+	prompt := a.render("clarify", prompts.Data{
+		Schema:   a.schemaSummary,
+		History:  a.formatHistory(),
+		Question: question,
+	})
+	raw, err := a.generateJSON(ctx, prompt, clarificationSchema)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Clarification check failed: %v", err)
+		}
+		return nil
+	}
+
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		log.Printf("Clarification check returned no JSON object: %s", truncate(raw, 200))
+		return nil
+	}
+	var out struct {
+		Ambiguous bool     `json:"ambiguous"`
+		Reason    string   `json:"reason"`
+		Question  string   `json:"question"`
+		Options   []string `json:"options"`
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &out); err != nil {
+		log.Printf("Clarification check returned invalid JSON: %v", err)
+		return nil
+	}
+	if !out.Ambiguous || strings.TrimSpace(out.Question) == "" {
+		return nil
+	}
+
+	clarification := &Clarification{ID: newClarificationID(), Question: strings.TrimSpace(out.Question)}
+	for _, option := range out.Options {
+		if option = strings.TrimSpace(option); option != "" && len(clarification.Options) < maxClarificationOptions {
+			clarification.Options = append(clarification.Options, option)
+		}
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "ask_clarification",
+		Observation: clarification.Question,
+		Thought:     out.Reason,
+	})
+	return clarification
+}
+
+// askClarification completes a response that asks the user to clarify, and
+// keeps the question pending until the user answers.
+func (a *Agent) askClarification(question, standalone, intent string, clarification *Clarification, response *AgentResponse) *AgentResponse {
+	a.pending = &pendingClarification{
+		Question:      question,
+		Standalone:    standalone,
+		Intent:        intent,
+		Clarification: *clarification,
+	}
+	response.NeedsClarification = true
+	response.Clarification = clarification
+	response.Answer = clarification.Question
+	response.Success = true
+	return response
+}
+
+// takePending returns the pending clarification when the question processed
+// with ctx replies to it, and clears it either way.
+func (a *Agent) takePending(ctx context.Context) *pendingClarification {
+	pending := a.pending
+	a.pending = nil
+	if pending == nil {
+		return nil
+	}
+	if id, _ := ctx.Value(clarificationKey{}).(string); id != pending.ID {
+		return nil
+	}
+	return pending
+}
+
+// resumeClarified combines the pending question with the user's reply into
+// the question to answer. A reply of "2" picks the second option.
+func (a *Agent) resumeClarified(pending *pendingClarification, reply string, response *AgentResponse) string {
+	answer := strings.TrimSpace(reply)
+	if n, err := strconv.Atoi(strings.TrimRight(answer, ".)")); err == nil && n >= 1 && n <= len(pending.Options) {
+		answer = pending.Options[n-1]
+	}
+
+	standalone := fmt.Sprintf("%s (asked %q, the user answered %q)", pending.Standalone, pending.Clarification.Question, answer)
+	a.addStep(response, ReasoningStep{
+		Action:      "apply_clarification",
+		Observation: standalone,
+		Thought:     "Continuing the previous question with the user's answer",
+	})
+	return standalone
+}
+
+func newClarificationID() string {
+	b := make([]byte, 8)
+	rand.Read(b) // only fails if the system has no randomness source
+	return hex.EncodeToString(b)
+}
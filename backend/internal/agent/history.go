@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gibranda/chat-with-database/internal/llm"
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+const (
+	defaultHistoryWindow      = 5
+	defaultHistoryTokenBudget = 1500
+)
+
+// conversationTurn is one answered question kept for follow-ups.
+type conversationTurn struct {
+	Question   string
+	Standalone string
+	SQL        string
+	Columns    []string
+	RowCount   int
+	Answer     string
+}
+
+// ConfigureHistory sets how many previous turns (and roughly how many
+// tokens of them) are fed back into prompts, and whether follow-up
+// questions are rewritten into standalone ones.
+func (a *Agent) ConfigureHistory(window, tokenBudget int, rewriteFollowUps bool) {
+	if window <= 0 {
+		window = defaultHistoryWindow
+	}
+	if tokenBudget <= 0 {
+		tokenBudget = defaultHistoryTokenBudget
+	}
+	a.historyWindow = window
+	a.historyTokenBudget = tokenBudget
+	a.rewriteFollowUps = rewriteFollowUps
+}
+
+// recordTurn stores a finished turn in both the structured history used for
+// prompts and the chat-style history used for GenerateWithContext. Only the
+// turns within the history window are kept.
+func (a *Agent) recordTurn(question, standalone, sql string, results *database.QueryResult, answer string) {
+	turn := conversationTurn{
+		Question:   question,
+		Standalone: standalone,
+		SQL:        sql,
+		Answer:     answer,
+	}
+	if results != nil {
+		turn.Columns = results.ColumnNames()
+		turn.RowCount = results.Count
+	}
+	window := a.historyWindow
+	if window <= 0 {
+		window = defaultHistoryWindow
+	}
+	a.turns = keepLast(append(a.turns, turn), window)
+
+	assistant := answer
+	if sql != "" {
+		assistant = fmt.Sprintf("SQL used:\n%s\n\n%s", sql, answer)
+	}
+	a.conversationHistory = keepLast(append(a.conversationHistory,
+		llm.ChatMessage{Role: "user", Content: question},
+		llm.ChatMessage{Role: "assistant", Content: assistant},
+	), 2*window)
+}
+
+// keepLast returns the last n elements of s, copied into a new slice when
+// some are dropped so they can be freed.
+func keepLast[T any](s []T, n int) []T {
+	if len(s) <= n {
+		return s
+	}
+	return append([]T(nil), s[len(s)-n:]...)
+}
+
+// recentTurns returns the newest turns that fit in the window and token budget,
+// oldest first.
+func (a *Agent) recentTurns() []conversationTurn {
+	window := a.historyWindow
+	if window <= 0 {
+		window = defaultHistoryWindow
+	}
+	budget := a.historyTokenBudget
+	if budget <= 0 {
+		budget = defaultHistoryTokenBudget
+	}
+
+	var selected []conversationTurn
+	used := 0
+	for i := len(a.turns) - 1; i >= 0 && len(selected) < window; i-- {
+		cost := estimateTokens(formatTurn(a.turns[i]))
+		if used+cost > budget && len(selected) > 0 {
+			break
+		}
+		used += cost
+		selected = append([]conversationTurn{a.turns[i]}, selected...)
+	}
+	return selected
+}
+
+// formatHistory renders recent turns for inclusion in a prompt. It returns
+// an empty string when there is no history.
+func (a *Agent) formatHistory() string {
+	turns := a.recentTurns()
+	if len(turns) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Previous conversation (oldest first):\n")
+	for i, turn := range turns {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s", i+1, formatTurn(turn)))
+	}
+	sb.WriteString("\nThe current question may refer to these turns (e.g. \"that\", \"now only for 2024\"). Reuse and modify the previous SQL where appropriate.\n\n")
+	return sb.String()
+}
+
+func formatTurn(turn conversationTurn) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Question: %s\n", turn.Question))
+	if turn.Standalone != "" && turn.Standalone != turn.Question {
+		sb.WriteString(fmt.Sprintf("Interpreted as: %s\n", turn.Standalone))
+	}
+	if turn.SQL != "" {
+		sb.WriteString(fmt.Sprintf("SQL: %s\n", turn.SQL))
+		sb.WriteString(fmt.Sprintf("Result: %d rows, columns: %s\n", turn.RowCount, strings.Join(turn.Columns, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("Answer: %s\n", truncate(turn.Answer, 300)))
+	return sb.String()
+}
+
+// rewriteFollowUp turns a follow-up question into a standalone one using
+// the previous turns. It returns the question unchanged when there is no
+// history or the rewrite fails.
+func (a *Agent) rewriteFollowUp(ctx context.Context, question string) string {
+	if !a.rewriteFollowUps || len(a.turns) == 0 {
+		return question
+	}
+
+	prompt := a.render("rewrite", prompts.Data{
+		History:  a.formatHistory(),
+		Question: question,
+	})
+
+	history := a.conversationHistory
+	if max := 2 * a.historyWindow; max > 0 && len(history) > max {
+		history = history[len(history)-max:]
+	}
+
+	system := a.render("rewrite_system", prompts.Data{})
+	rewritten, err := a.llm.GenerateWithContext(ctx, prompt, system, history)
+	if err != nil {
+		return question
+	}
+
+	rewritten = strings.Trim(strings.TrimSpace(rewritten), `"`)
+	if rewritten == "" {
+		return question
+	}
+	return rewritten
+}
+
+// estimateTokens is a rough 4-characters-per-token estimate.
+func estimateTokens(s string) int {
+	return len(s)/4 + 1
+}
@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecordTurnKeepsWindow(t *testing.T) {
+	a := &Agent{historyWindow: 3}
+	for i := 1; i <= 10; i++ {
+		a.recordTurn(fmt.Sprintf("question %d", i), "", "SELECT 1", nil, "answer")
+	}
+
+	if len(a.turns) != 3 || a.turns[0].Question != "question 8" || a.turns[2].Question != "question 10" {
+		t.Errorf("turns = %+v, want questions 8 to 10", a.turns)
+	}
+	if len(a.conversationHistory) != 6 || a.conversationHistory[0].Content != "question 8" {
+		t.Errorf("conversation history = %+v, want the last 3 exchanges", a.conversationHistory)
+	}
+}
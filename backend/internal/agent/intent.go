@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+// Intents a question can be routed by.
+const (
+	IntentSchema   = "schema"      // about tables, columns and relationships
+	IntentData     = "data"        // needs a query over the data
+	IntentFollowUp = "follow_up"   // refines the previous question
+	IntentExplain  = "explain_sql" // asks what a SQL query does
+	IntentChat     = "chit_chat"   // needs no database access
+	IntentWrite    = "write"       // asks to change data or tables
+)
+
+var intents = map[string]bool{
+	IntentSchema: true, IntentData: true, IntentFollowUp: true,
+	IntentExplain: true, IntentChat: true, IntentWrite: true,
+}
+
+// ConfigureIntents selects how questions are classified: "llm" (the
+// default) asks the model and falls back to rules when it fails, "rules"
+// only uses the rules.
+func (a *Agent) ConfigureIntents(classifier string) {
+	a.classifyWithLLM = classifier != "rules"
+}
+
+var (
+	sqlTextPattern         = regexp.MustCompile("(?is)```(?:sql)?\\s*(.+?)```|\\b((?:select\\b.+?\\bfrom\\b|with\\s+\\w+\\s+as\\s*\\(|insert\\s+into\\b|update\\s+\\S+\\s+set\\b|delete\\s+from\\b).*)")
+	explainPattern         = regexp.MustCompile(`(?i)\b(explain|what does|what do|meaning|mean|jelaskan|apa arti|apa maksud|maksud dari)\b`)
+	explainPreviousPattern = regexp.MustCompile(`(?i)\b(explain|jelaskan)\b.*\b(query|sql)\b`)
+	writePattern           = regexp.MustCompile(`(?i)^\s*(please\s+|tolong\s+)?((insert|update|delete|drop|truncate|alter|hapus|perbarui)\b|(create|add|remove|rename|modify|change|buat|tambah|tambahkan|ubah|ganti)\s+(\S+\s+){0,3}?(tables?|columns?|rows?|records?|index(es)?|views?|tabel|kolom|baris|data|entry|entries)\b)`)
+	chitChatPattern        = regexp.MustCompile(`(?i)^\s*(hi|hello|hey|halo|hai|thanks|thank you|thx|terima kasih|makasih|good (morning|afternoon|evening)|selamat (pagi|siang|sore|malam)|ok|okay|bye|who are you|siapa kamu)(\s+(there|all|everyone|a lot|so much|very much|you|ya|banyak|semua))*[\s!.?]*$`)
+	schemaPattern          = regexp.MustCompile(`(?i)^\s*(what|which|list|show( me)?|describe)\s+(all\s+)?(the\s+)?(tables|columns|schemas|views)\b|\b(apa saja|daftar|list)\s+(semua\s+)?(tabel|kolom|table|view)\b|\b(structure of|struktur|columns (of|in)|kolom (di|dari|pada))\b|^\s*describe\b`)
+	listTablesPattern      = regexp.MustCompile(`(?i)^\s*(what|which|list|show( me)?)\s+(all\s+)?(the\s+)?tables\b|\b(apa saja|daftar)\s+(semua\s+)?tabel\b`)
+	followUpPattern        = regexp.MustCompile(`(?i)^\s*(and|but|now|then|also|only|what about|how about|sort|order it|filter|same|dan|tapi|sekarang|lalu|hanya|bagaimana dengan|urutkan)\b`)
+)
+
+// classifyIntent decides what the question asks for, by a short LLM call
+// when enabled and by rules otherwise or when that call fails.
+func (a *Agent) classifyIntent(ctx context.Context, question string, response *AgentResponse) string {
+	intent, reason := "", ""
+	if a.classifyWithLLM {
+		var err error
+		intent, reason, err = a.classifyWithModel(ctx, question)
+		if err != nil {
+			if ctx.Err() != nil {
+				return IntentData
+			}
+			log.Printf("Intent classification failed, using rules: %v", err)
+		}
+	}
+	method := "model"
+	if intent == "" {
+		intent = classifyByRules(question, len(a.turns) > 0)
+		method, reason = "rules", "Matched question wording"
+	}
+
+	// A follow-up needs something to follow
+	if intent == IntentFollowUp && len(a.turns) == 0 {
+		intent = IntentData
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "classify_intent",
+		Observation: fmt.Sprintf("%s (%s)", intent, method),
+		Thought:     reason,
+	})
+	return intent
+}
+
+func (a *Agent) classifyWithModel(ctx context.Context, question string) (string, string, error) {
+	names := make([]string, 0, len(a.schemaCache.Tables))
+	for _, table := range a.schemaCache.Tables {
+		names = append(names, table.TableName.String())
+	}
+	if len(names) > maxListedTables {
+		names = append(names[:maxListedTables], "...")
+	}
+
+	prompt := a.render("intent", prompts.Data{
+		History:  a.formatHistory(),
+		Tables:   strings.Join(names, ", "),
+		Question: question,
+	})
+	raw, err := a.llm.Generate(ctx, prompt, "")
+	if err != nil {
+		return "", "", err
+	}
+
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		return "", "", fmt.Errorf("no JSON object in %q", truncate(raw, 200))
+	}
+	var out struct {
+		Intent string `json:"intent"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &out); err != nil {
+		return "", "", fmt.Errorf("invalid JSON: %w", err)
+	}
+	intent := strings.ToLower(strings.TrimSpace(out.Intent))
+	if !intents[intent] {
+		return "", "", fmt.Errorf("unknown intent %q", out.Intent)
+	}
+	return intent, out.Reason, nil
+}
+
+// classifyByRules is the deterministic classifier: it looks for SQL in the
+// question and for wording typical of each intent.
+func classifyByRules(question string, hasHistory bool) string {
+	switch {
+	case sqlInText(question) != "" && explainPattern.MatchString(question),
+		hasHistory && explainPreviousPattern.MatchString(question):
+		return IntentExplain
+	case writePattern.MatchString(question):
+		return IntentWrite
+	case chitChatPattern.MatchString(question):
+		return IntentChat
+	case schemaPattern.MatchString(question):
+		return IntentSchema
+	case hasHistory && followUpPattern.MatchString(question):
+		return IntentFollowUp
+	}
+	return IntentData
+}
+
+// sqlInText returns the SQL quoted in a question, if any.
+func sqlInText(text string) string {
+	m := sqlTextPattern.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	sql := m[1]
+	if sql == "" {
+		sql = m[2]
+		if i := strings.Index(sql, ";"); i >= 0 {
+			sql = sql[:i+1]
+		}
+	}
+	return strings.TrimSpace(sql)
+}
+
+// listTables answers "what tables are there" straight from the schema.
+func (a *Agent) listTables(response *AgentResponse) *AgentResponse {
+	var tableNames []string
+	for _, table := range a.schemaCache.Tables {
+		tableNames = append(tableNames, table.TableName.String())
+	}
+
+	answer := a.message("tables_header", len(tableNames)) + "\n\n"
+	for i, name := range tableNames {
+		answer += fmt.Sprintf("%d. **%s**\n", i+1, name)
+	}
+	answer += "\n" + a.message("tables_footer")
+
+	response.Success = true
+	response.Answer = answer
+	response.SQL = "-- Schema query (no SQL execution needed)"
+
+	a.addStep(response, ReasoningStep{
+		Action:      "list_tables",
+		Observation: fmt.Sprintf("Listed %d tables from schema", len(tableNames)),
+		Thought:     "Providing table list from cached schema",
+	})
+	return response
+}
+
+// answerSchemaQuestion answers a question about the database structure from
+// the schema, without running a query.
+func (a *Agent) answerSchemaQuestion(ctx context.Context, question string, response *AgentResponse) (*AgentResponse, error) {
+	if listTablesPattern.MatchString(question) {
+		return a.listTables(response), nil
+	}
+
+	a.schemaSummary = a.selectSchema(ctx, question, response)
+	prompt := a.render("schema", prompts.Data{
+		Schema:   a.schemaSummary,
+		History:  a.formatHistory(),
+		Question: question,
+	})
+	answer, err := a.generateAnswer(ctx, response, prompt)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return a.listTables(response), nil
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "answer_from_schema",
+		Observation: answer,
+		Thought:     "Answered from the schema, no query needed",
+	})
+	return a.finishWithoutQuery(question, "", answer, response), nil
+}
+
+// explainQuery explains the SQL in the question, or the previous turn's SQL
+// when the question refers to it, without running it.
+func (a *Agent) explainQuery(ctx context.Context, question string, response *AgentResponse) (*AgentResponse, error) {
+	sql := sqlInText(question)
+	if sql == "" && len(a.turns) > 0 {
+		sql = a.turns[len(a.turns)-1].SQL
+	}
+	if sql == "" {
+		response.Error = a.message("explain_no_sql")
+		return response, nil
+	}
+	response.SQL = sql
+
+	// Check the query against the database so problems can be pointed out.
+	// Queries failing validation never reach EXPLAIN, since EXPLAIN ANALYZE
+	// and extra statements would run.
+	var explainErr string
+	if err := a.checkSQL(sql); err != nil {
+		explainErr = err.Error()
+	} else if err := a.db.ExplainQuery(ctx, sql); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		explainErr = err.Error()
+	}
+
+	a.schemaSummary = a.selectSchema(ctx, question+"\n"+sql, response)
+	prompt := a.render("explain", prompts.Data{
+		Schema:   a.schemaSummary,
+		History:  a.formatHistory(),
+		Question: question,
+		SQL:      sql,
+		Error:    explainErr,
+	})
+	answer, err := a.generateAnswer(ctx, response, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "explain_sql",
+		Observation: answer,
+		Thought:     "Explained the query without running it",
+	})
+	return a.finishWithoutQuery(question, sql, answer, response), nil
+}
+
+// chat replies to small talk without touching the database.
+func (a *Agent) chat(ctx context.Context, question string, response *AgentResponse) (*AgentResponse, error) {
+	prompt := a.render("chat", prompts.Data{
+		History:  a.formatHistory(),
+		Question: question,
+	})
+	answer, err := a.generateAnswer(ctx, response, prompt)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		answer = a.message("chat_fallback")
+	}
+	return a.finishWithoutQuery(question, "", answer, response), nil
+}
+
+// finishWithoutQuery completes a response answered without results and
+// records it as a turn.
+func (a *Agent) finishWithoutQuery(question, sql, answer string, response *AgentResponse) *AgentResponse {
+	response.Answer = answer
+	response.Success = true
+	a.recordTurn(question, question, sql, nil, answer)
+	return response
+}
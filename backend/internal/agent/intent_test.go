@@ -0,0 +1,38 @@
+package agent
+
+import "testing"
+
+func TestClassifyByRules(t *testing.T) {
+	tests := []struct {
+		question   string
+		hasHistory bool
+		intent     string
+	}{
+		{"How many orders were placed last month?", false, IntentData},
+		{"berapa jumlah siswa per kelas", false, IntentData},
+		{"What does SELECT id FROM orders WHERE total > 10 do?", false, IntentExplain},
+		{"jelaskan ```sql\nSELECT * FROM t\n```", false, IntentExplain},
+		{"explain the query", true, IntentExplain},
+		{"explain the query", false, IntentData},
+		{"SELECT id FROM orders", false, IntentData},
+		{"delete all cancelled orders", false, IntentWrite},
+		{"please add a column for the phone number", false, IntentWrite},
+		{"hapus data siswa kelas 12", false, IntentWrite},
+		{"Add up the totals per month", false, IntentData},
+		{"hello there!", false, IntentChat},
+		{"terima kasih banyak", false, IntentChat},
+		{"hello, how many customers do we have?", false, IntentData},
+		{"What tables are there?", false, IntentSchema},
+		{"describe orders", false, IntentSchema},
+		{"apa saja kolom di tabel siswa", false, IntentSchema},
+		{"and only for 2024?", true, IntentFollowUp},
+		{"and only for 2024?", false, IntentData},
+		{"urutkan dari yang terbesar", true, IntentFollowUp},
+	}
+
+	for _, tt := range tests {
+		if got := classifyByRules(tt.question, tt.hasHistory); got != tt.intent {
+			t.Errorf("classifyByRules(%q, %v) = %q, want %q", tt.question, tt.hasHistory, got, tt.intent)
+		}
+	}
+}
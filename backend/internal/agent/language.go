@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+// defaultLanguage is the answer language when none is configured.
+const defaultLanguage = "id"
+
+type languageKey struct{}
+
+// WithLanguage overrides the configured answer language for the question
+// processed with the returned context.
+func WithLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// ConfigurePrompts sets the prompt templates and message catalogs, and the
+// language answers are given in: a code such as "en" or "id", or a
+// language name.
+func (a *Agent) ConfigurePrompts(set *prompts.Set, language string) {
+	if language == "" {
+		language = defaultLanguage
+	}
+	a.prompts = set
+	a.language = language
+	a.lang = set.Language(language)
+}
+
+// useLanguage picks the answer language for the question processed with
+// ctx.
+func (a *Agent) useLanguage(ctx context.Context) {
+	language := a.language
+	if override, ok := ctx.Value(languageKey{}).(string); ok && override != "" {
+		language = override
+	}
+	a.lang = a.prompts.Language(language)
+}
+
+// render fills the prompt template name in the current answer language.
+func (a *Agent) render(name string, data prompts.Data) string {
+	return a.prompts.Render(name, a.lang, data)
+}
+
+// message formats a user-facing message in the current answer language.
+func (a *Agent) message(key string, args ...interface{}) string {
+	return a.lang.Message(key, args...)
+}
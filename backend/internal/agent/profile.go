@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+)
+
+// maxProfiledTables caps how many tables are profiled for one question.
+const maxProfiledTables = 3
+
+// ConfigureProfiling turns column profiling on or off. When on, sampled
+// values of the tables a question mentions are added to SQL prompts so the
+// model uses literals that exist in the data.
+func (a *Agent) ConfigureProfiling(enabled bool) {
+	a.profileColumns = enabled
+}
+
+// valueHints profiles the tables named in text (the question and its plan)
+// and describes their column values for the SQL prompts. It returns "" when
+// profiling is off or no table is mentioned.
+func (a *Agent) valueHints(ctx context.Context, text string, response *AgentResponse) string {
+	if !a.profileColumns {
+		return ""
+	}
+
+	var sb strings.Builder
+	var profiled []string
+	for _, table := range a.mentionedTables(text) {
+		profile, err := a.db.ProfileTable(ctx, table)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		summary := profile.Summary()
+		if summary == "" {
+			continue
+		}
+		sb.WriteString(summary)
+		sb.WriteString("\n")
+		profiled = append(profiled, table.TableName.String())
+	}
+	if len(profiled) == 0 {
+		return ""
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "profile_columns",
+		Observation: fmt.Sprintf("Sampled column values of %s", strings.Join(profiled, ", ")),
+		Thought:     "Checking which values exist so filters use real literals",
+	})
+
+	return "Column values found in the data (use these exact spellings in filters; enum-like columns allow only the listed values):\n" + sb.String()
+}
+
+// mentionedTables returns the tables and materialized views whose names
+// appear as words in text, in schema order.
+func (a *Agent) mentionedTables(text string) []database.TableInfo {
+	var tables []database.TableInfo
+	for _, table := range a.schemaCache.Tables {
+		if table.Type == database.TableTypeView {
+			continue
+		}
+		pattern := `(?i)\b` + regexp.QuoteMeta(table.Name) + `\b`
+		if matched, _ := regexp.MatchString(pattern, text); matched {
+			tables = append(tables, table)
+			if len(tables) == maxProfiledTables {
+				break
+			}
+		}
+	}
+	return tables
+}
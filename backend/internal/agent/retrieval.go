@@ -0,0 +1,401 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gibranda/chat-with-database/internal/llm"
+)
+
+const (
+	// defaultSchemaMaxChars is the largest schema summary sent whole
+	defaultSchemaMaxChars = 16000
+	// embedBatchSize is how many table descriptions go in one embed request
+	embedBatchSize = 64
+	// maxListedTables caps the names of left-out tables listed in prompts
+	maxListedTables = 200
+	// embedRetryInterval is how long keyword matching is used after
+	// embedding the schema failed, before trying again
+	embedRetryInterval = time.Minute
+)
+
+// EmbeddingStore keeps schema embeddings between sessions. Vectors are
+// keyed by the embedding model and a hash of the embedded text, so they
+// stay valid until the table changes.
+type EmbeddingStore interface {
+	GetEmbeddings(ctx context.Context, model string, keys []string) (map[string][]float32, error)
+	PutEmbeddings(ctx context.Context, model string, vectors map[string][]float32) error
+}
+
+// ConfigureRetrieval enables schema pruning for large databases: when the
+// schema summary is longer than maxChars, prompts describe only the topK
+// tables most relevant to the question plus the tables linked to them by
+// foreign keys. Tables are ranked by embedding similarity when the LLM
+// provider has an embedding model, and by keyword matching otherwise. A
+// topK of zero always sends the whole schema; store may be nil.
+func (a *Agent) ConfigureRetrieval(topK, maxChars int, store EmbeddingStore) {
+	if maxChars <= 0 {
+		maxChars = defaultSchemaMaxChars
+	}
+	a.retrievalTopK = topK
+	a.schemaMaxChars = maxChars
+	a.embeddings = store
+}
+
+// schemaIndex ranks the tables of one SchemaInfo against questions.
+type schemaIndex struct {
+	schema  *database.SchemaInfo
+	docs    []string // per table, the text that is embedded
+	terms   []tableTerms
+	vectors [][]float32 // per table; nil when embeddings are unavailable
+
+	// embedFailed is when embedding the tables last failed
+	embedFailed time.Time
+}
+
+// tableTerms are the normalized words describing a table.
+type tableTerms struct {
+	name  map[string]bool // from the table name
+	other map[string]bool // from columns and descriptions
+}
+
+// selectSchema returns the schema text for this question's prompts: the
+// whole summary for small schemas, otherwise the relevant tables only.
+func (a *Agent) selectSchema(ctx context.Context, question string, response *AgentResponse) string {
+	schema := a.schemaCache
+	if a.retrievalTopK <= 0 || len(schema.Tables) <= a.retrievalTopK || len(schema.Summary) <= a.schemaMaxChars {
+		return schema.Summary
+	}
+
+	if a.tableIndex == nil || a.tableIndex.schema != schema {
+		index := a.buildSchemaIndex(ctx)
+		if ctx.Err() != nil {
+			return schema.Summary
+		}
+		a.tableIndex = index
+	} else if index := a.tableIndex; !index.embedFailed.IsZero() && time.Since(index.embedFailed) >= embedRetryInterval {
+		// The embedding model may have become available, e.g. once loaded
+		a.embedSchemaIndex(ctx, index)
+	}
+
+	scores, method := a.rankTables(ctx, question)
+	selected := a.pickTables(question, scores)
+
+	names := make([]database.TableName, len(selected))
+	labels := make([]string, len(selected))
+	chosen := make(map[int]bool, len(selected))
+	for i, t := range selected {
+		names[i] = schema.Tables[t].TableName
+		labels[i] = names[i].String()
+		chosen[t] = true
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "select_tables",
+		Observation: fmt.Sprintf("Using %d of %d tables (%s): %s", len(selected), len(schema.Tables), method, strings.Join(labels, ", ")),
+		Thought:     "The schema is too large to send whole; keeping the tables relevant to the question",
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("The database has %d tables and views; the %d most relevant to this question are described below. Use describe_table to see any other table.\n\n",
+		len(schema.Tables), len(selected)))
+	sb.WriteString(schema.Subset(names).Summary)
+
+	var others []string
+	for i, table := range schema.Tables {
+		if !chosen[i] {
+			others = append(others, table.TableName.String())
+		}
+	}
+	if len(others) > maxListedTables {
+		others = append(others[:maxListedTables], fmt.Sprintf("... and %d more", len(others)-maxListedTables))
+	}
+	if len(others) > 0 {
+		sb.WriteString("\nOther tables: " + strings.Join(others, ", ") + "\n")
+	}
+	return sb.String()
+}
+
+// buildSchemaIndex collects each table's terms and, when possible, its
+// embedding.
+func (a *Agent) buildSchemaIndex(ctx context.Context) *schemaIndex {
+	schema := a.schemaCache
+	index := &schemaIndex{
+		schema: schema,
+		docs:   make([]string, len(schema.Tables)),
+		terms:  make([]tableTerms, len(schema.Tables)),
+	}
+	for i, table := range schema.Tables {
+		index.docs[i] = tableDocument(table)
+		index.terms[i] = tableTerms{name: termSet(table.Name), other: termSet(index.docs[i])}
+	}
+
+	a.embedSchemaIndex(ctx, index)
+	return index
+}
+
+// embedSchemaIndex adds the table embeddings to index, reusing stored
+// vectors for tables that did not change. On failure the index keeps using
+// keyword matching and records when, so embedding is retried later.
+func (a *Agent) embedSchemaIndex(ctx context.Context, index *schemaIndex) {
+	embedder, ok := a.llm.(llm.Embedder)
+	if !ok || embedder.EmbeddingModel() == "" {
+		return
+	}
+
+	vectors, err := a.embedDocuments(ctx, embedder, index.docs)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Schema embeddings unavailable, using keyword matching: %v", err)
+			index.embedFailed = time.Now()
+		}
+		return
+	}
+	index.vectors = vectors
+	index.embedFailed = time.Time{}
+}
+
+// embedDocuments embeds docs, taking what it can from the embedding store
+// and saving the rest there.
+func (a *Agent) embedDocuments(ctx context.Context, embedder llm.Embedder, docs []string) ([][]float32, error) {
+	model := embedder.EmbeddingModel()
+	keys := make([]string, len(docs))
+	for i, doc := range docs {
+		sum := sha256.Sum256([]byte(doc))
+		keys[i] = hex.EncodeToString(sum[:])
+	}
+
+	stored := map[string][]float32{}
+	if a.embeddings != nil {
+		var err error
+		if stored, err = a.embeddings.GetEmbeddings(ctx, model, keys); err != nil {
+			log.Printf("Failed to read stored embeddings: %v", err)
+			stored = map[string][]float32{}
+		}
+	}
+
+	var missing []int
+	for i, key := range keys {
+		if _, ok := stored[key]; !ok {
+			missing = append(missing, i)
+		}
+	}
+
+	fresh := make(map[string][]float32, len(missing))
+	for start := 0; start < len(missing); start += embedBatchSize {
+		batch := missing[start:min(start+embedBatchSize, len(missing))]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = docs[i]
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range batch {
+			fresh[keys[i]] = vectors[j]
+			stored[keys[i]] = vectors[j]
+		}
+	}
+	if a.embeddings != nil && len(fresh) > 0 {
+		if err := a.embeddings.PutEmbeddings(ctx, model, fresh); err != nil {
+			log.Printf("Failed to store embeddings: %v", err)
+		}
+	}
+
+	vectors := make([][]float32, len(docs))
+	for i, key := range keys {
+		vectors[i] = stored[key]
+	}
+	return vectors, nil
+}
+
+// rankTables scores every table against the question, by cosine similarity
+// of embeddings when available and keyword overlap otherwise. It also
+// names the method used.
+func (a *Agent) rankTables(ctx context.Context, question string) ([]float64, string) {
+	index := a.tableIndex
+	if index.vectors != nil {
+		if embedder, ok := a.llm.(llm.Embedder); ok {
+			vectors, err := embedder.Embed(ctx, []string{question})
+			if err == nil {
+				scores := make([]float64, len(index.vectors))
+				for i, v := range index.vectors {
+					scores[i] = cosine(vectors[0], v)
+				}
+				return scores, "embeddings"
+			}
+			log.Printf("Failed to embed question, using keyword matching: %v", err)
+		}
+	}
+
+	words := termSet(question)
+	scores := make([]float64, len(index.terms))
+	for i, terms := range index.terms {
+		for word := range words {
+			switch {
+			case terms.name[word]:
+				scores[i] += 3
+			case terms.other[word]:
+				scores[i]++
+			}
+		}
+	}
+	return scores, "keyword matching"
+}
+
+// pickTables takes the tables named in the question and the best scoring
+// ones up to topK, then adds their foreign key neighbours, up to twice topK
+// tables in all. Ties go to the larger table.
+func (a *Agent) pickTables(question string, scores []float64) []int {
+	schema := a.schemaCache
+	order := make([]int, len(schema.Tables))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		i, j := order[x], order[y]
+		if scores[i] != scores[j] {
+			return scores[i] > scores[j]
+		}
+		return schema.Tables[i].RowCount > schema.Tables[j].RowCount
+	})
+
+	var selected []int
+	chosen := make(map[int]bool)
+	add := func(i int) {
+		if !chosen[i] {
+			chosen[i] = true
+			selected = append(selected, i)
+		}
+	}
+
+	words := termSet(question)
+	for i, terms := range a.tableIndex.terms {
+		if len(terms.name) > 0 && containsAll(words, terms.name) {
+			add(i)
+		}
+	}
+	for _, i := range order {
+		if len(selected) >= a.retrievalTopK {
+			break
+		}
+		add(i)
+	}
+
+	byName := make(map[database.TableName]int, len(schema.Tables))
+	for i, table := range schema.Tables {
+		byName[table.TableName] = i
+	}
+	limit := 2 * a.retrievalTopK
+	for _, i := range append([]int(nil), selected...) {
+		name := schema.Tables[i].TableName
+		for _, rel := range schema.Relationships {
+			if len(selected) >= limit {
+				return selected
+			}
+			from := database.TableName{Schema: rel.FromSchema, Name: rel.FromTable}
+			to := database.TableName{Schema: rel.ToSchema, Name: rel.ToTable}
+			switch name {
+			case from:
+				if j, ok := byName[to]; ok {
+					add(j)
+				}
+			case to:
+				if j, ok := byName[from]; ok {
+					add(j)
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// tableDocument is the text embedded for a table: its name, description
+// and columns with their descriptions.
+func tableDocument(table database.TableInfo) string {
+	var sb strings.Builder
+	sb.WriteString(table.TableName.String())
+	if table.Description != "" {
+		sb.WriteString(": " + table.Description)
+	}
+	sb.WriteString("\nColumns: ")
+	for i, col := range table.Columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(col.Name)
+		if col.Description != "" {
+			sb.WriteString(" (" + col.Description + ")")
+		}
+	}
+	return sb.String()
+}
+
+var (
+	wordPattern      = regexp.MustCompile(`[\p{L}\p{N}]+`)
+	camelCasePattern = regexp.MustCompile(`(\p{Ll})(\p{Lu})`)
+)
+
+// stopWords are common question words that say nothing about tables.
+var stopWords = map[string]bool{
+	"the": true, "a": true, "an": true, "of": true, "and": true, "or": true, "in": true, "for": true,
+	"to": true, "by": true, "with": true, "what": true, "which": true, "how": true, "many": true,
+	"show": true, "list": true, "all": true, "per": true, "each": true, "is": true, "are": true,
+	"yang": true, "dan": true, "di": true, "dari": true, "ke": true, "untuk": true, "per-": true,
+	"berapa": true, "apa": true, "tampilkan": true, "semua": true, "setiap": true, "dengan": true,
+}
+
+// termSet splits text into lower-case words, breaking snake_case and
+// camelCase names apart and dropping plural endings.
+func termSet(text string) map[string]bool {
+	text = camelCasePattern.ReplaceAllString(text, "$1 $2")
+	terms := make(map[string]bool)
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if stopWords[word] {
+			continue
+		}
+		switch {
+		case len(word) > 4 && strings.HasSuffix(word, "ies"):
+			word = strings.TrimSuffix(word, "ies") + "y"
+		case len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+			word = strings.TrimSuffix(word, "s")
+		}
+		terms[word] = true
+	}
+	return terms
+}
+
+func containsAll(set, subset map[string]bool) bool {
+	for word := range subset {
+		if !set[word] {
+			return false
+		}
+	}
+	return true
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gibranda/chat-with-database/internal/llm"
+)
+
+// flakyEmbedder fails its first few calls, like a model that is still
+// loading.
+type flakyEmbedder struct {
+	llm.Provider
+	failures int
+	calls    int
+}
+
+func (e *flakyEmbedder) EmbeddingModel() string { return "test" }
+
+func (e *flakyEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, errors.New("model is loading")
+	}
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = []float32{1, float32(i)}
+	}
+	return vectors, nil
+}
+
+func TestSelectSchemaRetriesEmbedding(t *testing.T) {
+	schema := &database.SchemaInfo{Summary: strings.Repeat("x", 100)}
+	for _, name := range []string{"orders", "customers", "products"} {
+		schema.Tables = append(schema.Tables, database.TableInfo{TableName: database.TableName{Name: name}})
+	}
+	embedder := &flakyEmbedder{failures: 1}
+	a := &Agent{llm: embedder, schemaCache: schema, retrievalTopK: 1, schemaMaxChars: 10}
+	ctx := context.Background()
+
+	a.selectSchema(ctx, "how many orders", &AgentResponse{})
+	if a.tableIndex.vectors != nil || a.tableIndex.embedFailed.IsZero() {
+		t.Fatal("failed embedding was not recorded")
+	}
+
+	// Within the retry interval keyword matching is used without asking again
+	calls := embedder.calls
+	a.selectSchema(ctx, "how many orders", &AgentResponse{})
+	if embedder.calls != calls {
+		t.Fatalf("embedding retried after %d calls, before the retry interval", calls)
+	}
+
+	a.tableIndex.embedFailed = time.Now().Add(-embedRetryInterval)
+	response := &AgentResponse{}
+	a.selectSchema(ctx, "how many orders", response)
+	if a.tableIndex.vectors == nil {
+		t.Fatal("embedding was not retried")
+	}
+	if !a.tableIndex.embedFailed.IsZero() {
+		t.Error("embedFailed not cleared after a successful retry")
+	}
+	if len(response.Reasoning) == 0 || !strings.Contains(response.Reasoning[0].Observation, "(embeddings)") {
+		t.Errorf("tables not ranked by embeddings: %+v", response.Reasoning)
+	}
+}
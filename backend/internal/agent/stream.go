@@ -0,0 +1,36 @@
+package agent
+
+import "context"
+
+// StreamEvent is emitted while a query is being processed.
+type StreamEvent struct {
+	Type     string         `json:"type"` // start, step, token, result, error
+	QueryID  string         `json:"query_id,omitempty"`
+	Step     *ReasoningStep `json:"step,omitempty"`
+	Token    string         `json:"token,omitempty"`
+	Response *AgentResponse `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// addStep numbers and records a reasoning step, emitting it to the
+// response's stream listener if there is one.
+func (a *Agent) addStep(response *AgentResponse, step ReasoningStep) {
+	step.Step = len(response.Reasoning) + 1
+	response.Reasoning = append(response.Reasoning, step)
+
+	if response.emit != nil {
+		response.emit(StreamEvent{Type: "step", Step: &step})
+	}
+}
+
+// generateAnswer runs the answer prompt, streaming tokens to the listener
+// when the response is being streamed.
+func (a *Agent) generateAnswer(ctx context.Context, response *AgentResponse, prompt string) (string, error) {
+	if response.emit == nil {
+		return a.llm.Generate(ctx, prompt, a.getSystemPrompt())
+	}
+
+	return a.llm.GenerateStream(ctx, prompt, a.getSystemPrompt(), func(token string) {
+		response.emit(StreamEvent{Type: "token", Token: token})
+	})
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/llm"
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+// maxJSONRetries is how often a reply that fails validation is sent back
+// for correction before falling back to scraping SQL from it.
+const maxJSONRetries = 2
+
+// sqlOutput is the reply requested when generating SQL in JSON mode.
+type sqlOutput struct {
+	SQL         string   `json:"sql"`
+	TablesUsed  []string `json:"tables_used"`
+	Assumptions []string `json:"assumptions"`
+	Confidence  float64  `json:"confidence"`
+}
+
+var sqlOutputSchema = json.RawMessage(`{
+  "type": "object",
+  "properties": {
+    "sql": {"type": "string"},
+    "tables_used": {"type": "array", "items": {"type": "string"}},
+    "assumptions": {"type": "array", "items": {"type": "string"}},
+    "confidence": {"type": "number", "minimum": 0, "maximum": 1}
+  },
+  "required": ["sql", "tables_used", "assumptions", "confidence"]
+}`)
+
+// toolCallSchema constrains the tool loop's replies to a ToolCall.
+var toolCallSchema = func() json.RawMessage {
+	names := make([]string, len(agentTools))
+	for i, tool := range agentTools {
+		names[i] = tool.Name
+	}
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"thought":   map[string]string{"type": "string"},
+			"tool":      map[string]interface{}{"type": "string", "enum": names},
+			"arguments": map[string]string{"type": "object"},
+		},
+		"required": []string{"thought", "tool", "arguments"},
+	})
+	return schema
+}()
+
+// ConfigureSQLOutput selects how the model returns SQL: "json" (the
+// default) requests a JSON object with the query, the tables it uses, the
+// assumptions made and a confidence, "text" scrapes the query from free
+// text.
+func (a *Agent) ConfigureSQLOutput(mode string) {
+	a.jsonOutput = mode != "text"
+}
+
+// generateJSON asks for a reply following schema, constraining the output
+// when the provider supports it.
+func (a *Agent) generateJSON(ctx context.Context, prompt string, schema json.RawMessage) (string, error) {
+	if generator, ok := a.llm.(llm.JSONGenerator); ok {
+		return generator.GenerateJSON(ctx, prompt, a.getSystemPrompt(), schema)
+	}
+	return a.llm.Generate(ctx, prompt, a.getSystemPrompt())
+}
+
+// generateSQL runs a SQL generation prompt and returns the query. In JSON
+// mode, replies failing validation are sent back with the problem up to
+// maxJSONRetries times; the query, tables used, assumptions and confidence
+// of the accepted reply are recorded on response.
+func (a *Agent) generateSQL(ctx context.Context, prompt string, response *AgentResponse) (string, error) {
+	if !a.jsonOutput {
+		raw, err := a.llm.Generate(ctx, prompt, a.getSystemPrompt())
+		if err != nil {
+			return "", err
+		}
+		return a.extractSQL(raw), nil
+	}
+
+	current := prompt
+	var raw string
+	for attempt := 0; ; attempt++ {
+		var err error
+		raw, err = a.generateJSON(ctx, current, sqlOutputSchema)
+		if err != nil {
+			return "", err
+		}
+
+		out, err := parseSQLOutput(raw)
+		if err == nil {
+			response.TablesUsed = out.TablesUsed
+			response.Assumptions = out.Assumptions
+			response.Confidence = &out.Confidence
+			return strings.TrimSpace(out.SQL), nil
+		}
+		if attempt == maxJSONRetries {
+			break
+		}
+
+		a.addStep(response, ReasoningStep{
+			Action:      "invalid_sql_output",
+			Observation: err.Error(),
+			Thought:     "Asking the model to correct its reply",
+		})
+		current = a.render("json_retry", prompts.Data{
+			Prompt: prompt,
+			Reply:  truncate(raw, maxObservationLength),
+			Error:  err.Error(),
+		})
+	}
+
+	// The model never produced valid JSON; salvage what looks like SQL
+	return a.extractSQL(raw), nil
+}
+
+// parseSQLOutput extracts the JSON object from raw and checks it against
+// sqlOutputSchema.
+func parseSQLOutput(raw string) (*sqlOutput, error) {
+	start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("the reply is not a JSON object")
+	}
+	text := []byte(raw[start : end+1])
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(text, &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for _, name := range []string{"sql", "tables_used", "assumptions", "confidence"} {
+		if _, ok := fields[name]; !ok {
+			return nil, fmt.Errorf("missing field %q", name)
+		}
+	}
+
+	var out sqlOutput
+	if err := json.Unmarshal(text, &out); err != nil {
+		return nil, fmt.Errorf("wrong field type: %w", err)
+	}
+	if strings.TrimSpace(out.SQL) == "" {
+		return nil, fmt.Errorf("\"sql\" is empty")
+	}
+	if out.Confidence < 0 || out.Confidence > 1 {
+		return nil, fmt.Errorf("\"confidence\" must be between 0 and 1, got %v", out.Confidence)
+	}
+	return &out, nil
+}
+
+// argStrings reads a list of strings from tool arguments.
+func argStrings(args map[string]interface{}, key string) []string {
+	list, _ := args[key].([]interface{})
+	var values []string
+	for _, v := range list {
+		if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+			values = append(values, strings.TrimSpace(s))
+		}
+	}
+	return values
+}
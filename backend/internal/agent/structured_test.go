@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSQLOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want *sqlOutput
+		err  string // part of the error message, when parsing fails
+	}{
+		{
+			name: "valid",
+			raw:  `{"sql": "SELECT id FROM t", "tables_used": ["t"], "assumptions": [], "confidence": 0.9}`,
+			want: &sqlOutput{SQL: "SELECT id FROM t", TablesUsed: []string{"t"}, Assumptions: []string{}, Confidence: 0.9},
+		},
+		{
+			name: "surrounding text",
+			raw:  "Here you go:\n```json\n{\"sql\": \"SELECT 1\", \"tables_used\": [], \"assumptions\": [\"x\"], \"confidence\": 1}\n```",
+			want: &sqlOutput{SQL: "SELECT 1", TablesUsed: []string{}, Assumptions: []string{"x"}, Confidence: 1},
+		},
+		{name: "no object", raw: "SELECT 1", err: "not a JSON object"},
+		{name: "invalid json", raw: `{"sql": "SELECT 1",}`, err: "invalid JSON"},
+		{name: "missing field", raw: `{"sql": "SELECT 1", "tables_used": [], "assumptions": []}`, err: `missing field "confidence"`},
+		{name: "wrong type", raw: `{"sql": "SELECT 1", "tables_used": "t", "assumptions": [], "confidence": 1}`, err: "wrong field type"},
+		{name: "empty sql", raw: `{"sql": " ", "tables_used": [], "assumptions": [], "confidence": 1}`, err: `"sql" is empty`},
+		{name: "confidence out of range", raw: `{"sql": "SELECT 1", "tables_used": [], "assumptions": [], "confidence": 80}`, err: "between 0 and 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSQLOutput(tt.raw)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseSQLOutput() error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSQLOutput() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSQLOutput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gibranda/chat-with-database/internal/prompts"
+)
+
+const (
+	defaultMaxIterations = 5
+	maxObservationLength = 2000
+	defaultSampleRows    = 5
+	maxSampleRows        = 20
+)
+
+// Tool describes an action the model may take inside the reasoning loop.
+type Tool struct {
+	Name        string
+	Description string
+	Arguments   string
+}
+
+var agentTools = []Tool{
+	{
+		Name:        "list_tables",
+		Description: "List every table and view in the database with its row count.",
+		Arguments:   "{}",
+	},
+	{
+		Name:        "describe_table",
+		Description: "Show the columns, types, keys, indexes and constraints of one table, or a view's definition.",
+		Arguments:   `{"table": "table name"}`,
+	},
+	{
+		Name:        "sample_rows",
+		Description: "Fetch a few example rows from a table to see real values.",
+		Arguments:   `{"table": "table name", "limit": 5}`,
+	},
+	{
+		Name:        "explain_sql",
+		Description: "Check that a SQL query is valid without running it.",
+		Arguments:   `{"sql": "SELECT ..."}`,
+	},
+	{
+		Name:        "run_sql",
+		Description: "Execute a read-only SQL query and see its results.",
+		Arguments:   `{"sql": "SELECT ..."}`,
+	},
+	{
+		Name:        "final_answer",
+		Description: "Finish. Pass the SQL whose results answer the question, or an answer when no query is needed, with the assumptions you made and how confident (0 to 1) you are.",
+		Arguments:   `{"sql": "SELECT ... (optional)", "answer": "short answer (optional)", "assumptions": ["..."], "confidence": 0.8}`,
+	},
+}
+
+// toolExchange is one tool call and the observation it produced.
+type toolExchange struct {
+	call        ToolCall
+	observation string
+}
+
+// loopOutcome is what the tool loop settled on.
+type loopOutcome struct {
+	sql     string
+	results *database.QueryResult
+	answer  string
+}
+
+// runToolLoop lets the model call tools up to maxIterations times, recording
+// every call and observation as a reasoning step.
+func (a *Agent) runToolLoop(ctx context.Context, question, plan, hints string, response *AgentResponse) (*loopOutcome, error) {
+	maxIterations := a.maxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxIterations
+	}
+
+	outcome := &loopOutcome{}
+	var transcript []toolExchange
+
+	for i := 0; i < maxIterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		prompt := a.buildToolPrompt(question, plan, hints, transcript, maxIterations-i)
+		var raw string
+		var err error
+		if a.jsonOutput {
+			raw, err = a.generateJSON(ctx, prompt, toolCallSchema)
+		} else {
+			raw, err = a.llm.Generate(ctx, prompt, a.getSystemPrompt())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate tool call: %w", err)
+		}
+
+		call, err := parseToolCall(raw)
+		if err != nil {
+			observation := fmt.Sprintf("Invalid tool call (%v). Respond with a single JSON object as instructed.", err)
+			transcript = append(transcript, toolExchange{call: ToolCall{Tool: "invalid"}, observation: observation})
+			a.addStep(response, ReasoningStep{
+				Action:      "invalid_tool_call",
+				Observation: observation,
+				Thought:     truncate(raw, 200),
+			})
+			continue
+		}
+
+		if call.Tool == "final_answer" {
+			finalFailed := false
+			if sql := argString(call.Arguments, "sql"); sql != "" && (outcome.results == nil || sql != outcome.sql) {
+				// The model committed to SQL it has not run yet (or not as its last query)
+				observation, ok := a.toolRunSQL(ctx, sql, outcome)
+				a.addStep(response, ReasoningStep{
+					Action:      "run_sql",
+					Observation: observation,
+					Thought:     "Executing the final query",
+				})
+				if !ok {
+					// Rows of an earlier query must not pass for this one's;
+					// leave it to the one-shot pipeline, which reports errors
+					outcome.sql, outcome.results = "", nil
+					finalFailed = true
+				}
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if !finalFailed {
+				if outcome.results == nil {
+					outcome.answer = argString(call.Arguments, "answer")
+				}
+				response.Assumptions = argStrings(call.Arguments, "assumptions")
+				if confidence, ok := call.Arguments["confidence"].(float64); ok && confidence >= 0 && confidence <= 1 {
+					response.Confidence = &confidence
+				}
+			}
+			a.addStep(response, ReasoningStep{
+				Action:      "final_answer",
+				Observation: fmt.Sprintf("Finished after %d tool calls", i+1),
+				Thought:     call.Thought,
+			})
+			return outcome, nil
+		}
+
+		observation := a.executeTool(ctx, call, outcome)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		transcript = append(transcript, toolExchange{call: *call, observation: observation})
+		a.addStep(response, ReasoningStep{
+			Action:      formatToolCall(*call),
+			Observation: observation,
+			Thought:     call.Thought,
+		})
+	}
+
+	a.addStep(response, ReasoningStep{
+		Action:      "max_iterations",
+		Observation: fmt.Sprintf("Reached the limit of %d tool calls", maxIterations),
+		Thought:     "Using the last successful query, if any",
+	})
+
+	return outcome, nil
+}
+
+// executeTool runs a single tool call and returns its observation.
+func (a *Agent) executeTool(ctx context.Context, call *ToolCall, outcome *loopOutcome) string {
+	switch call.Tool {
+	case "list_tables":
+		return a.toolListTables()
+	case "describe_table":
+		return a.toolDescribeTable(argString(call.Arguments, "table"))
+	case "sample_rows":
+		return a.toolSampleRows(ctx, argString(call.Arguments, "table"), argInt(call.Arguments, "limit", defaultSampleRows))
+	case "explain_sql":
+		return a.toolExplainSQL(ctx, argString(call.Arguments, "sql"))
+	case "run_sql":
+		observation, _ := a.toolRunSQL(ctx, argString(call.Arguments, "sql"), outcome)
+		return observation
+	default:
+		return fmt.Sprintf("Unknown tool %q. Available tools: %s", call.Tool, toolNames())
+	}
+}
+
+func (a *Agent) toolListTables() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d tables and views:\n", len(a.schemaCache.Tables)))
+	for _, table := range a.schemaCache.Tables {
+		switch table.Type {
+		case database.TableTypeView:
+			sb.WriteString(fmt.Sprintf("- %s (view)\n", table.TableName))
+		case database.TableTypeMaterializedView:
+			sb.WriteString(fmt.Sprintf("- %s (materialized view, %d rows)\n", table.TableName, table.RowCount))
+		default:
+			sb.WriteString(fmt.Sprintf("- %s (%d rows)\n", table.TableName, table.RowCount))
+		}
+	}
+	return sb.String()
+}
+
+func (a *Agent) toolDescribeTable(name string) string {
+	table := a.findTable(name)
+	if table == nil {
+		return fmt.Sprintf("Table %q not found. %s", name, a.generateHints("", fmt.Sprintf("relation %q does not exist", name)))
+	}
+
+	return table.Summary()
+}
+
+func (a *Agent) toolSampleRows(ctx context.Context, name string, limit int) string {
+	table := a.findTable(name)
+	if table == nil {
+		return fmt.Sprintf("Table %q not found. %s", name, a.generateHints("", fmt.Sprintf("relation %q does not exist", name)))
+	}
+	if limit <= 0 || limit > maxSampleRows {
+		limit = defaultSampleRows
+	}
+
+	// Only table names taken from the schema cache reach the query text
+	results, err := a.db.ExecuteQuery(ctx, fmt.Sprintf("SELECT * FROM %s", table.TableName), limit)
+	if err != nil {
+		return fmt.Sprintf("Failed to sample rows: %v", err)
+	}
+	return formatResultsObservation(results)
+}
+
+func (a *Agent) toolExplainSQL(ctx context.Context, sql string) string {
+	if strings.TrimSpace(sql) == "" {
+		return "Missing required argument: sql"
+	}
+	// EXPLAIN ANALYZE and extra statements run, so they get run_sql's checks
+	if err := a.checkSQL(sql); err != nil {
+		return err.Error()
+	}
+	if err := a.db.ExplainQuery(ctx, sql); err != nil {
+		return fmt.Sprintf("EXPLAIN failed: %v. %s", err, a.generateHints(sql, err.Error()))
+	}
+	return "EXPLAIN succeeded: the query is valid"
+}
+
+// toolRunSQL validates and executes sql, recording it in outcome on success.
+func (a *Agent) toolRunSQL(ctx context.Context, sql string, outcome *loopOutcome) (string, bool) {
+	sql = strings.TrimSpace(sql)
+	if sql == "" {
+		return "Missing required argument: sql", false
+	}
+
+	if err := a.checkSQL(sql); err != nil {
+		return err.Error(), false
+	}
+
+	results, err := a.db.ExecuteQuery(ctx, sql, a.maxResults)
+	if err != nil {
+		return fmt.Sprintf("Query execution failed: %v. %s", err, a.generateHints(sql, err.Error())), false
+	}
+
+	outcome.sql = sql
+	outcome.results = results
+	return formatResultsObservation(results), true
+}
+
+// checkSQL applies query validation and the readonly policy to sql.
+func (a *Agent) checkSQL(sql string) error {
+	if !a.enableQueryValidation {
+		return nil
+	}
+
+	verdict := a.db.AnalyzeSQL(sql)
+	if err := verdict.Err(); err != nil {
+		return fmt.Errorf("SQL validation failed: %w", err)
+	}
+	if a.readonlyMode && !verdict.ReadOnly {
+		return fmt.Errorf("only read-only queries are allowed in readonly mode: %s", verdict.Reason)
+	}
+	return nil
+}
+
+func (a *Agent) findTable(name string) *database.TableInfo {
+	name = strings.TrimSpace(name)
+	for i := range a.schemaCache.Tables {
+		if strings.EqualFold(a.schemaCache.Tables[i].TableName.String(), name) {
+			return &a.schemaCache.Tables[i]
+		}
+	}
+	// Fall back to the unqualified name, taking the first schema that has it
+	for i := range a.schemaCache.Tables {
+		if strings.EqualFold(a.schemaCache.Tables[i].Name, name) {
+			return &a.schemaCache.Tables[i]
+		}
+	}
+	return nil
+}
+
+func (a *Agent) buildToolPrompt(question, plan, hints string, transcript []toolExchange, remaining int) string {
+	var tools strings.Builder
+	for _, tool := range agentTools {
+		tools.WriteString(fmt.Sprintf("- %s: %s Arguments: %s\n", tool.Name, tool.Description, tool.Arguments))
+	}
+
+	var history strings.Builder
+	if len(transcript) == 0 {
+		history.WriteString("(no tool calls yet)\n")
+	}
+	for i, ex := range transcript {
+		history.WriteString(fmt.Sprintf("%d. %s\nObservation: %s\n\n", i+1, formatToolCall(ex.call), truncate(ex.observation, maxObservationLength)))
+	}
+
+	return a.render("tools", prompts.Data{
+		Schema:     a.schemaSummary,
+		Hints:      hints,
+		History:    a.formatHistory(),
+		Question:   question,
+		Plan:       plan,
+		Tools:      tools.String(),
+		Transcript: history.String(),
+		Remaining:  remaining,
+	})
+}
+
+// parseToolCall extracts the first JSON object from the model output.
+func parseToolCall(raw string) (*ToolCall, error) {
+	start := strings.Index(raw, "{")
+	end := strings.LastIndex(raw, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("no JSON object found")
+	}
+
+	var call ToolCall
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &call); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if call.Tool == "" {
+		return nil, fmt.Errorf("missing \"tool\" field")
+	}
+	if call.Arguments == nil {
+		call.Arguments = map[string]interface{}{}
+	}
+	return &call, nil
+}
+
+func formatToolCall(call ToolCall) string {
+	if len(call.Arguments) == 0 {
+		return call.Tool
+	}
+
+	keys := make([]string, 0, len(call.Arguments))
+	for k := range call.Arguments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	args := make([]string, 0, len(keys))
+	for _, k := range keys {
+		args = append(args, fmt.Sprintf("%s=%v", k, call.Arguments[k]))
+	}
+	return fmt.Sprintf("%s(%s)", call.Tool, strings.Join(args, ", "))
+}
+
+func formatResultsObservation(results *database.QueryResult) string {
+	return truncate(fmt.Sprintf("Retrieved %s. First rows:\n%s",
+		rowCount(results), previewResults(results, 5)), maxObservationLength)
+}
+
+// rowCount describes how many rows a result holds, making clear when the
+// row cap cut it short so the model doesn't treat the count as a total.
+func rowCount(results *database.QueryResult) string {
+	if results.Truncated {
+		return fmt.Sprintf("the first %d rows; the query returned more and was capped at %d", results.Count, results.Limit)
+	}
+	return fmt.Sprintf("%d rows total", results.Count)
+}
+
+// previewResults renders up to n rows for a prompt: a JSON array of column
+// names followed by one JSON array per row, in the same order.
+func previewResults(results *database.QueryResult, n int) string {
+	var sb strings.Builder
+	header, _ := json.Marshal(results.ColumnNames())
+	sb.Write(header)
+	for i, row := range results.Rows {
+		if i >= n {
+			break
+		}
+		line, _ := json.Marshal(row)
+		sb.WriteByte('\n')
+		sb.Write(line)
+	}
+	return sb.String()
+}
+
+func toolNames() string {
+	names := make([]string, 0, len(agentTools))
+	for _, tool := range agentTools {
+		names = append(names, tool.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func argString(args map[string]interface{}, key string) string {
+	if v, ok := args[key].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+func argInt(args map[string]interface{}, key string, fallback int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return fallback
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
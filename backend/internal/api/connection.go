@@ -1,23 +1,34 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gibranda/chat-with-database/internal/agent"
+	"github.com/gibranda/chat-with-database/internal/config"
 	"github.com/gibranda/chat-with-database/internal/database"
 )
 
+// ConnectionRequest carries either explicit connection settings or the
+// name of a saved connection profile.
 type ConnectionRequest struct {
-	Type     string `json:"type" binding:"required"`     // postgres, mysql, sqlite3
+	Profile  string `json:"profile"`
+	Type     string `json:"type"`     // postgres, mysql, sqlite3
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
-	Database string `json:"database" binding:"required"`
+	Database string `json:"database"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	SSLMode  string `json:"sslmode"`
 	Path     string `json:"path"` // for SQLite
+	// Postgres schemas / MySQL databases to introspect (names or globs)
+	Schemas        []string `json:"schemas"`
+	ExcludeSchemas []string `json:"exclude_schemas"`
 }
 
 type ConnectionResponse struct {
@@ -33,11 +44,17 @@ func (h *Handler) TestConnection(c *gin.Context) {
 		return
 	}
 
-	// Build connection string
-	connStr := buildConnectionString(req)
+	dbCfg, err := h.resolveConnection(req)
+	if err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
 
 	// Try to connect
-	testDB, err := database.New(req.Type, connStr)
+	testDB, err := database.Open(c.Request.Context(), dbCfg.Type, dbCfg.ConnectionString(), database.Options{
+		Schemas:        dbCfg.Schemas,
+		ExcludeSchemas: dbCfg.ExcludeSchemas,
+	})
 	if err != nil {
 		c.JSON(http.StatusOK, ConnectionResponse{
 			Success: false,
@@ -48,7 +65,7 @@ func (h *Handler) TestConnection(c *gin.Context) {
 	defer testDB.Close()
 
 	// Get table count to verify connection
-	tables, err := testDB.GetTables()
+	tables, err := testDB.GetTables(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusOK, ConnectionResponse{
 			Success: false,
@@ -71,11 +88,16 @@ func (h *Handler) Connect(c *gin.Context) {
 		return
 	}
 
-	// Build connection string
-	connStr := buildConnectionString(req)
+	dbCfg, err := h.resolveConnection(req)
+	if err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	// Connect to new database
-	newDB, err := database.New(req.Type, connStr)
+	sess := currentSession(c)
+	sess.mu.Lock()
+	newDB, err := h.connectSession(c.Request.Context(), sess, req.Profile, dbCfg)
+	sess.mu.Unlock()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -84,16 +106,37 @@ func (h *Handler) Connect(c *gin.Context) {
 		return
 	}
 
-	// Close old database connection
-	if h.db != nil {
-		h.db.Close()
+	log.Printf("✓ Connected to %s database: %s", dbCfg.Type, dbCfg.Name)
+
+	response := gin.H{
+		"success":   true,
+		"message":   "Connected successfully",
+		"type":      dbCfg.Type,
+		"database":  dbCfg.Name,
+		"read_only": newDB.ReadOnly(),
 	}
+	if req.Profile != "" {
+		response["profile"] = req.Profile
+	}
+	c.JSON(http.StatusOK, response)
+}
 
-	// Update handler with new connection
-	h.db = newDB
+// connectSession opens the database described by dbCfg (from the named
+// profile, if any) and replaces the session's connection and agent with it.
+// The caller must hold sess.mu.
+func (h *Handler) connectSession(ctx context.Context, sess *Session, profile string, dbCfg config.DatabaseConfig) (*database.Database, error) {
+	// Readonly mode is enforced by the database session itself
+	newDB, err := database.Open(ctx, dbCfg.Type, dbCfg.ConnectionString(), database.Options{
+		ReadOnly:       h.config.Agent.ReadonlyMode,
+		QueryTimeout:   time.Duration(h.config.Timeouts.SQL) * time.Second,
+		Schemas:        dbCfg.Schemas,
+		ExcludeSchemas: dbCfg.ExcludeSchemas,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Initialize agent with new database
-	h.agent = agent.NewAgent(
+	agentInstance := agent.NewAgent(
 		h.llmClient,
 		newDB,
 		h.config.Agent.MaxIterations,
@@ -101,27 +144,73 @@ func (h *Handler) Connect(c *gin.Context) {
 		h.config.Agent.ReadonlyMode,
 		h.config.Agent.MaxResults,
 	)
+	agentInstance.ConfigureHistory(
+		h.config.Agent.HistoryWindow,
+		h.config.Agent.HistoryTokenBudget,
+		h.config.Agent.RewriteFollowUps,
+	)
+	agentInstance.ConfigureProfiling(h.config.Agent.ProfileColumns)
+	agentInstance.ConfigureRetrieval(h.config.Agent.SchemaTopK, h.config.Agent.SchemaMaxChars, h.history)
+	agentInstance.ConfigurePrompts(h.prompts, h.config.Agent.Language)
+	agentInstance.ConfigureIntents(h.config.Agent.IntentClassifier)
+	agentInstance.ConfigureSQLOutput(h.config.Agent.SQLOutput)
+	agentInstance.ConfigureClarification(h.config.Agent.ClarifyQuestions)
+
+	// Replace this session's connection, closing the old one
+	sess.setConnection(newDB, agentInstance)
+	sess.conn = connectionInfo{Profile: profile, Type: dbCfg.Type, Database: dbCfg.Name}
+	if dbCfg.Type == "sqlite3" && dbCfg.Name == "" {
+		sess.conn.Database = dbCfg.Path
+	}
 
-	log.Printf("✓ Connected to %s database: %s", req.Type, req.Database)
+	// Take the version first, so an edit made meanwhile is reloaded later
+	sess.descriptionsVersion = h.descriptions.get(sess.conn.key())
+	overrides, err := h.history.ListDescriptions(ctx, sess.conn.key())
+	if err != nil {
+		log.Printf("Failed to load schema descriptions: %v", err)
+	}
+	newDB.SetDescriptionOverrides(overrides)
+	return newDB, nil
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success":  true,
-		"message":  "Connected successfully",
-		"type":     req.Type,
-		"database": req.Database,
-	})
+// resolveConnection turns a request into connection settings, looking up
+// the named profile when one is given.
+func (h *Handler) resolveConnection(req ConnectionRequest) (config.DatabaseConfig, error) {
+	if req.Profile != "" {
+		profile, err := h.profiles.Get(req.Profile)
+		if err != nil {
+			return config.DatabaseConfig{}, fmt.Errorf("%w: %s", err, req.Profile)
+		}
+		return profile.DatabaseConfig(), nil
+	}
+
+	if req.Type == "" || req.Database == "" {
+		return config.DatabaseConfig{}, errors.New("either profile or type and database are required")
+	}
+	return config.DatabaseConfig{
+		Type:           req.Type,
+		Host:           req.Host,
+		Port:           req.Port,
+		Name:           req.Database,
+		User:           req.User,
+		Password:       req.Password,
+		SSLMode:        req.SSLMode,
+		Path:           req.Path,
+		Schemas:        req.Schemas,
+		ExcludeSchemas: req.ExcludeSchemas,
+	}, nil
 }
 
 func (h *Handler) Disconnect(c *gin.Context) {
-	// Close database connection if exists
-	if h.db != nil {
-		h.db.Close()
-		h.db = nil
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	// Close database connection and clear agent if exists
+	if sess.db != nil {
 		log.Println("✓ Database disconnected")
 	}
-
-	// Clear agent
-	h.agent = nil
+	sess.setConnection(nil, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -130,15 +219,22 @@ func (h *Handler) Disconnect(c *gin.Context) {
 }
 
 func (h *Handler) GetConnectionStatus(c *gin.Context) {
-	connected := h.db != nil && h.agent != nil
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	connected := sess.connected()
 	
 	response := gin.H{
 		"connected": connected,
 	}
+	if sess.db != nil {
+		response["read_only"] = sess.db.ReadOnly()
+	}
 
 	if connected {
 		// Try to get database info
-		tables, err := h.db.GetTables()
+		tables, err := sess.db.GetTables(c.Request.Context())
 		if err == nil {
 			response["tables"] = len(tables)
 		}
@@ -146,33 +242,3 @@ func (h *Handler) GetConnectionStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
-
-func buildConnectionString(req ConnectionRequest) string {
-	switch req.Type {
-	case "postgres":
-		sslmode := req.SSLMode
-		if sslmode == "" {
-			sslmode = "disable"
-		}
-		return database.BuildPostgresConnString(
-			req.Host,
-			req.Port,
-			req.Database,
-			req.User,
-			req.Password,
-			sslmode,
-		)
-	case "mysql":
-		return database.BuildMySQLConnString(
-			req.Host,
-			req.Port,
-			req.Database,
-			req.User,
-			req.Password,
-		)
-	case "sqlite3":
-		return req.Path
-	default:
-		return ""
-	}
-}
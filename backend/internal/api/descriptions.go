@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// descriptionRequest sets the description of a table, or of one of its
+// columns when Column is given. An empty description removes the override
+// so the database comment, if any, is used again.
+type descriptionRequest struct {
+	Table       string `json:"table" binding:"required"`
+	Column      string `json:"column"`
+	Description string `json:"description"`
+}
+
+// descriptionVersions counts description edits per connection key, so
+// sessions on the same connection pick up edits made by another session.
+type descriptionVersions struct {
+	mu       sync.Mutex
+	versions map[string]uint64
+}
+
+func (v *descriptionVersions) get(key string) uint64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.versions[key]
+}
+
+func (v *descriptionVersions) bump(key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.versions == nil {
+		v.versions = make(map[string]uint64)
+	}
+	v.versions[key]++
+}
+
+// syncDescriptions reloads the description overrides of the session's
+// connection and the agent's schema when they changed since the session
+// loaded them. The caller must hold sess.mu.
+func (h *Handler) syncDescriptions(ctx context.Context, sess *Session) error {
+	key := sess.conn.key()
+	version := h.descriptions.get(key)
+	if version == sess.descriptionsVersion {
+		return nil
+	}
+
+	overrides, err := h.history.ListDescriptions(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to load schema descriptions: %w", err)
+	}
+	sess.db.SetDescriptionOverrides(overrides)
+	if err := sess.agent.RefreshSchema(ctx); err != nil {
+		return err
+	}
+	sess.descriptionsVersion = version
+	return nil
+}
+
+// ListDescriptions returns the description overrides stored for the
+// session's connection.
+func (h *Handler) ListDescriptions(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if !sess.connected() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Database not connected. Please connect to a database first.",
+		})
+		return
+	}
+
+	overrides, err := h.history.ListDescriptions(c.Request.Context(), sess.conn.key())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"descriptions": overrides})
+}
+
+// SetDescription stores a table or column description for the session's
+// connection and reloads the schema so the LLM sees it. Other sessions on
+// the same connection reload it before their next use of the schema. It
+// returns the table with the new descriptions applied.
+func (h *Handler) SetDescription(c *gin.Context) {
+	var req descriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if !sess.connected() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Database not connected. Please connect to a database first.",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	table, err := sess.db.FindTable(ctx, strings.TrimSpace(req.Table))
+	if err != nil {
+		c.JSON(statusForTableError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	override := database.DescriptionOverride{
+		Schema:      table.Schema,
+		Table:       table.Name,
+		Description: req.Description,
+	}
+	if column := strings.TrimSpace(req.Column); column != "" {
+		info, err := sess.db.GetTableInfo(ctx, table)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, col := range info.Columns {
+			if strings.EqualFold(col.Name, column) {
+				override.Column = col.Name
+				break
+			}
+		}
+		if override.Column == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "column " + column + " not found in " + table.TableName.String()})
+			return
+		}
+	}
+
+	connection := sess.conn.key()
+	if err := h.history.SetDescription(ctx, connection, override); err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.descriptions.bump(connection)
+
+	if err := h.syncDescriptions(ctx, sess); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := sess.db.GetTableInfo(ctx, table)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
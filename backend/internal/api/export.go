@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gibranda/chat-with-database/internal/export"
+	"github.com/gin-gonic/gin"
+)
+
+// exportSource is the stored SQL an export re-executes.
+type exportSource struct {
+	name    string // used for the file name
+	sql     string
+	profile string
+}
+
+// Export re-executes the SQL of a history entry (?history_id=) or saved
+// query (?saved_query_id=) without the max_results cap and streams the full
+// result as ?format=csv|xlsx|ndjson|parquet. Only stored SQL can be
+// exported, and it goes through the same validation as any other run.
+//
+// Errors before the first byte is written are reported as JSON. A failure
+// mid-stream closes the connection, so the client sees an incomplete
+// download rather than a truncated file that looks complete.
+func (h *Handler) Export(c *gin.Context) {
+	formatName := c.DefaultQuery("format", "csv")
+	format, ok := export.LookupFormat(formatName)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("unsupported format %q, use one of: %s", formatName, strings.Join(export.FormatNames(), ", ")),
+		})
+		return
+	}
+
+	source, ok := h.exportSource(c)
+	if !ok {
+		return
+	}
+
+	sess := currentSession(c)
+	ctx, queryID, finish, err := h.startQuery(c, sess, c.Query("query_id"))
+	if err != nil {
+		return
+	}
+	defer finish()
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	// Check if database is connected
+	if !sess.connected() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Database not connected. Please connect to a database first."})
+		return
+	}
+	if source.profile != "" && source.profile != sess.conn.Profile {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("This query was run against profile %q; connect to it before exporting", source.profile),
+		})
+		return
+	}
+
+	out := &exportResponse{c: c, format: format, filename: exportFilename(source.name, format)}
+	writer := format.New(out)
+	rows := 0
+	start := time.Now()
+
+	err = sess.agent.StreamSQL(ctx, source.sql,
+		func(columns []database.ResultColumn) error {
+			if len(columns) == 0 {
+				return errors.New("the query does not return any rows to export")
+			}
+			return writer.WriteHeader(columns)
+		},
+		func(values []interface{}) error {
+			rows++
+			return writer.WriteRow(values)
+		})
+	if err == nil {
+		err = writer.Close()
+	}
+
+	if err != nil {
+		log.Printf("Export %s failed after %d rows: %v", queryID, rows, err)
+		if !out.started {
+			status, message := queryErrorStatus(err)
+			c.JSON(status, gin.H{"error": message, "query_id": queryID})
+			return
+		}
+		abortResponse(c)
+		return
+	}
+
+	log.Printf("Exported %d rows as %s in %s", rows, format.Name, time.Since(start).Round(time.Millisecond))
+}
+
+// exportSource loads the SQL named by the request, writing an error
+// response when it cannot.
+func (h *Handler) exportSource(c *gin.Context) (exportSource, bool) {
+	historyID, savedID := c.Query("history_id"), c.Query("saved_query_id")
+	if (historyID == "") == (savedID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify either history_id or saved_query_id"})
+		return exportSource{}, false
+	}
+
+	if historyID != "" {
+		id, err := strconv.ParseInt(historyID, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid history_id"})
+			return exportSource{}, false
+		}
+		entry, err := h.history.GetHistory(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+			return exportSource{}, false
+		}
+		if !entry.Success || strings.TrimSpace(entry.SQL) == "" || strings.HasPrefix(entry.SQL, "--") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This history entry has no successful query to export"})
+			return exportSource{}, false
+		}
+		return exportSource{name: fmt.Sprintf("query-%d", id), sql: entry.SQL, profile: entry.Profile}, true
+	}
+
+	id, err := strconv.ParseInt(savedID, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid saved_query_id"})
+		return exportSource{}, false
+	}
+	saved, err := h.history.GetSavedQuery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return exportSource{}, false
+	}
+	return exportSource{name: saved.Name, sql: saved.SQL, profile: saved.Profile}, true
+}
+
+// exportResponse sends the download headers with the first write, so that
+// errors raised before any output can still be reported as JSON.
+type exportResponse struct {
+	c        *gin.Context
+	format   export.Format
+	filename string
+	started  bool
+}
+
+func (r *exportResponse) Write(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		r.c.Header("Content-Type", r.format.ContentType)
+		r.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, r.filename))
+		r.c.Header("Cache-Control", "no-store")
+		r.c.Status(http.StatusOK)
+	}
+	return r.c.Writer.Write(p)
+}
+
+// abortResponse drops the connection of a response that has already
+// started, so the client does not mistake a partial body for a full one.
+func abortResponse(c *gin.Context) {
+	// gin refuses to hijack once the body is written; the server does not
+	w := http.ResponseWriter(c.Writer)
+	if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+		w = u.Unwrap()
+	}
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	c.Abort()
+}
+
+// exportFilename turns name into a safe file name with the format's
+// extension and a timestamp.
+func exportFilename(name string, format export.Format) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	base := strings.Trim(b.String(), "-")
+	if base == "" {
+		base = "export"
+	}
+	return fmt.Sprintf("%s-%s.%s", base, time.Now().Format("20060102-150405"), format.Extension)
+}
@@ -1,25 +1,40 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gibranda/chat-with-database/internal/agent"
 	"github.com/gibranda/chat-with-database/internal/config"
 	"github.com/gibranda/chat-with-database/internal/database"
 	"github.com/gibranda/chat-with-database/internal/llm"
+	"github.com/gibranda/chat-with-database/internal/profiles"
+	"github.com/gibranda/chat-with-database/internal/prompts"
+	"github.com/gibranda/chat-with-database/internal/store"
 )
 
 type Handler struct {
-	agent     *agent.Agent
-	db        *database.Database
-	llmClient *llm.OllamaClient
-	config    *config.Config
+	sessions     *SessionManager
+	queries      *QueryRegistry
+	profiles     *profiles.Store
+	history      *store.Store
+	prompts      *prompts.Set
+	llmClient    llm.Provider
+	config       *config.Config
+	descriptions descriptionVersions
 }
 
 type QueryRequest struct {
 	Question string `json:"question" binding:"required"`
+	ID       string `json:"id"`       // optional client-chosen id, used to cancel the run
+	Language string `json:"language"` // optional answer language, overriding agent.language
+	// ClarificationID marks the question as the answer to that clarification
+	ClarificationID string `json:"clarification_id"`
 }
 
 type SchemaResponse struct {
@@ -27,15 +42,22 @@ type SchemaResponse struct {
 }
 
 type HealthResponse struct {
-	Status   string `json:"status"`
-	Database string `json:"database"`
-	LLM      string `json:"llm"`
+	Status   string        `json:"status"`
+	Database string        `json:"database"`
+	LLM      string        `json:"llm"`
+	Model    llm.ModelInfo `json:"model"`
 }
 
-func NewHandler(agentInstance *agent.Agent, db *database.Database, llmClient *llm.OllamaClient, cfg *config.Config) *Handler {
+func NewHandler(llmClient llm.Provider, profileStore *profiles.Store, historyStore *store.Store, promptSet *prompts.Set, cfg *config.Config) *Handler {
 	return &Handler{
-		agent:     agentInstance,
-		db:        db,
+		sessions: NewSessionManager(
+			cfg.Server.MaxSessions,
+			time.Duration(cfg.Server.SessionIdleTimeout)*time.Second,
+		),
+		queries:   NewQueryRegistry(),
+		profiles:  profileStore,
+		history:   historyStore,
+		prompts:   promptSet,
 		llmClient: llmClient,
 		config:    cfg,
 	}
@@ -43,7 +65,7 @@ func NewHandler(agentInstance *agent.Agent, db *database.Database, llmClient *ll
 
 func (h *Handler) Health(c *gin.Context) {
 	dbStatus := "not_connected"
-	if h.db != nil {
+	if sess, ok := h.lookupSession(c); ok && sess.hasDB.Load() {
 		dbStatus = "connected"
 	}
 	
@@ -51,12 +73,32 @@ func (h *Handler) Health(c *gin.Context) {
 		Status:   "healthy",
 		Database: dbStatus,
 		LLM:      "connected",
+		Model:    h.llmClient.ModelInfo(),
 	})
 }
 
 func (h *Handler) Query(c *gin.Context) {
+	sess := currentSession(c)
+
+	var req QueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Error binding JSON: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Register the run before waiting for the session so it can be cancelled
+	ctx, queryID, finish, err := h.startQuery(c, sess, req.ID)
+	if err != nil {
+		return
+	}
+	defer finish()
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if database is connected
-	if h.db == nil || h.agent == nil {
+	if !sess.connected() {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Database not connected. Please connect to a database first.",
@@ -64,6 +106,40 @@ func (h *Handler) Query(c *gin.Context) {
 		return
 	}
 
+	log.Printf("Processing query %s: %s", queryID, req.Question)
+	if err := h.syncDescriptions(ctx, sess); err != nil {
+		log.Printf("Failed to reload schema descriptions: %v", err)
+	}
+
+	start := time.Now()
+	if req.Language != "" {
+		ctx = agent.WithLanguage(ctx, req.Language)
+	}
+	if req.ClarificationID != "" {
+		ctx = agent.WithClarificationReply(ctx, req.ClarificationID)
+	}
+	response, err := sess.agent.ProcessQuery(ctx, req.Question)
+	historyID := h.recordQuery(sess, store.SourceAgent, req.Question, response, err, time.Since(start))
+	if err != nil {
+		log.Printf("Error processing query: %v", err)
+		status, message := queryErrorStatus(err)
+		c.JSON(status, gin.H{"error": message, "query_id": queryID})
+		return
+	}
+
+	log.Printf("Query processed successfully")
+	response.HistoryID = historyID
+	response.ResultID = sess.results.add(response)
+	c.JSON(http.StatusOK, response)
+}
+
+// QueryStream processes a question like Query but streams Server-Sent
+// Events: a "start" event carrying the query id, a "step" event per
+// reasoning step, "token" events while the answer is generated, and a
+// final "result" (or "error") event carrying the full response.
+func (h *Handler) QueryStream(c *gin.Context) {
+	sess := currentSession(c)
+
 	var req QueryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Printf("Error binding JSON: %v", err)
@@ -71,29 +147,116 @@ func (h *Handler) Query(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Processing query: %s", req.Question)
-
-	response, err := h.agent.ProcessQuery(req.Question)
+	// Register the run before waiting for the session so it can be cancelled
+	ctx, queryID, finish, err := h.startQuery(c, sess, req.ID)
 	if err != nil {
-		log.Printf("Error processing query: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	running := false
+	defer func() {
+		if !running {
+			finish()
+		}
+	}()
 
-	log.Printf("Query processed successfully")
-	c.JSON(http.StatusOK, response)
+	sess.mu.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			sess.mu.Unlock()
+		}
+	}()
+
+	// Check if database is connected
+	if !sess.connected() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Database not connected. Please connect to a database first.",
+		})
+		return
+	}
+
+	log.Printf("Processing streamed query %s: %s", queryID, req.Question)
+	if err := h.syncDescriptions(ctx, sess); err != nil {
+		log.Printf("Failed to reload schema descriptions: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	done := c.Request.Context().Done()
+	events := make(chan agent.StreamEvent, 16)
+	send := func(ev agent.StreamEvent) {
+		select {
+		case events <- ev:
+		case <-done:
+		}
+	}
+
+	if req.Language != "" {
+		ctx = agent.WithLanguage(ctx, req.Language)
+	}
+	if req.ClarificationID != "" {
+		ctx = agent.WithClarificationReply(ctx, req.ClarificationID)
+	}
+
+	// The goroutine owns the session lock and the run until the agent is
+	// done; the run's context ends when the client goes away
+	locked = false
+	running = true
+	go func() {
+		defer sess.mu.Unlock()
+		defer finish()
+		defer close(events)
+
+		send(agent.StreamEvent{Type: "start", QueryID: queryID})
+		start := time.Now()
+		response, err := sess.agent.ProcessQueryStream(ctx, req.Question, send)
+		historyID := h.recordQuery(sess, store.SourceAgent, req.Question, response, err, time.Since(start))
+		if err != nil {
+			log.Printf("Error processing query: %v", err)
+			_, message := queryErrorStatus(err)
+			send(agent.StreamEvent{Type: "error", QueryID: queryID, Error: message})
+			return
+		}
+		response.HistoryID = historyID
+		response.ResultID = sess.results.add(response)
+		send(agent.StreamEvent{Type: "result", QueryID: queryID, Response: response})
+	}()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(ev.Type, ev)
+			return true
+		case <-done:
+			return false
+		}
+	})
 }
 
 func (h *Handler) GetSchema(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if database is connected
-	if h.db == nil || h.agent == nil {
+	if !sess.connected() {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Database not connected. Please connect to a database first.",
 		})
 		return
 	}
 
-	schema, err := h.agent.GetSchema()
+	if err := h.syncDescriptions(c.Request.Context(), sess); err != nil {
+		log.Printf("Failed to reload schema descriptions: %v", err)
+	}
+	schema, err := sess.agent.GetSchema(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -103,15 +266,21 @@ func (h *Handler) GetSchema(c *gin.Context) {
 }
 
 func (h *Handler) RefreshSchema(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if database is connected
-	if h.db == nil || h.agent == nil {
+	if !sess.connected() {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Database not connected. Please connect to a database first.",
 		})
 		return
 	}
 
-	if err := h.agent.RefreshSchema(); err != nil {
+	// The data may have changed too
+	sess.db.ClearProfiles()
+	if err := sess.agent.RefreshSchema(c.Request.Context()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -120,39 +289,55 @@ func (h *Handler) RefreshSchema(c *gin.Context) {
 }
 
 func (h *Handler) ClearHistory(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if agent is initialized
-	if h.agent == nil {
+	if sess.agent == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Database not connected. Please connect to a database first.",
 		})
 		return
 	}
 
-	h.agent.ClearHistory()
+	sess.agent.ClearHistory()
 	c.JSON(http.StatusOK, gin.H{"message": "Conversation history cleared"})
 }
 
 func (h *Handler) GetTables(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if database is connected
-	if h.db == nil {
+	if sess.db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Database not connected. Please connect to a database first.",
 		})
 		return
 	}
 
-	tables, err := h.db.GetTables()
+	tables, err := sess.db.GetTables(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tables": tables})
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.String()
+	}
+	c.JSON(http.StatusOK, gin.H{"tables": names})
 }
 
 func (h *Handler) GetTableInfo(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
 	// Check if database is connected
-	if h.db == nil {
+	if sess.db == nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Database not connected. Please connect to a database first.",
 		})
@@ -165,7 +350,18 @@ func (h *Handler) GetTableInfo(c *gin.Context) {
 		return
 	}
 
-	info, err := h.db.GetTableInfo(tableName)
+	if err := h.syncDescriptions(c.Request.Context(), sess); err != nil {
+		log.Printf("Failed to reload schema descriptions: %v", err)
+	}
+
+	// Accepts "schema.table" or a table name unique across schemas
+	table, err := sess.db.FindTable(c.Request.Context(), tableName)
+	if err != nil {
+		c.JSON(statusForTableError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	info, err := sess.db.GetTableInfo(c.Request.Context(), table)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -173,3 +369,72 @@ func (h *Handler) GetTableInfo(c *gin.Context) {
 
 	c.JSON(http.StatusOK, info)
 }
+
+// GetTableProfile samples a table's column values: distinct counts, NULL
+// ratios, ranges and common values. Profiles are cached for a while.
+func (h *Handler) GetTableProfile(c *gin.Context) {
+	sess := currentSession(c)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.db == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Database not connected. Please connect to a database first.",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	table, err := sess.db.FindTable(ctx, c.Param("table"))
+	if err != nil {
+		c.JSON(statusForTableError(err), gin.H{"error": err.Error()})
+		return
+	}
+	if table.Type == database.TableTypeView {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "views are not profiled"})
+		return
+	}
+
+	info, err := sess.db.GetTableInfo(ctx, table)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := sess.db.ProfileTable(ctx, *info)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func statusForTableError(err error) int {
+	switch {
+	case errors.Is(err, database.ErrTableNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrAmbiguousTable):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// startQuery registers a cancellable run for the request, bounded by the
+// configured query timeout, and sets the X-Query-ID header. On failure it
+// writes the error response itself.
+func (h *Handler) startQuery(c *gin.Context, sess *Session, id string) (context.Context, string, func(), error) {
+	timeout := time.Duration(h.config.Timeouts.Query) * time.Second
+	ctx, queryID, finish, err := h.queries.Start(c.Request.Context(), sess.ID, id, timeout)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrQueryIDInUse) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, "", nil, err
+	}
+
+	c.Header(QueryIDHeader, queryID)
+	return ctx, queryID, finish, nil
+}
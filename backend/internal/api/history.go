@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/agent"
+	"github.com/gibranda/chat-with-database/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// savedQueryRequest creates or partially updates a saved query. When
+// HistoryID is set on create, missing fields are taken from that entry.
+type savedQueryRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Question    *string `json:"question"`
+	SQL         *string `json:"sql"`
+	Profile     *string `json:"profile"`
+	Pinned      *bool   `json:"pinned"`
+	HistoryID   int64   `json:"history_id"`
+}
+
+func (r savedQueryRequest) applyTo(q *store.SavedQuery) {
+	if r.Name != nil {
+		q.Name = *r.Name
+	}
+	if r.Description != nil {
+		q.Description = *r.Description
+	}
+	if r.Question != nil {
+		q.Question = *r.Question
+	}
+	if r.SQL != nil {
+		q.SQL = *r.SQL
+	}
+	if r.Profile != nil {
+		q.Profile = *r.Profile
+	}
+	if r.Pinned != nil {
+		q.Pinned = *r.Pinned
+	}
+}
+
+// recordQuery stores a finished run in the query history, returning the
+// new entry's ID (0 if it could not be stored). The caller must hold
+// sess.mu.
+func (h *Handler) recordQuery(sess *Session, source, question string, response *agent.AgentResponse, runErr error, elapsed time.Duration) int64 {
+	entry := &store.HistoryEntry{
+		Source:     source,
+		Profile:    sess.conn.Profile,
+		DBType:     sess.conn.Type,
+		DBName:     sess.conn.Database,
+		Question:   question,
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if response != nil {
+		entry.SQL = response.SQL
+		entry.Answer = response.Answer
+		entry.Success = response.Success
+		entry.Error = response.Error
+		entry.Reasoning, _ = json.Marshal(response.Reasoning)
+		if response.Results != nil {
+			entry.RowCount = response.Results.Count
+		}
+	}
+	if runErr != nil {
+		entry.Success = false
+		_, entry.Error = queryErrorStatus(runErr)
+	}
+
+	// Record cancelled runs too, so use a context independent of the request
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.history.AddHistory(ctx, entry); err != nil {
+		log.Printf("Failed to record query history: %v", err)
+		return 0
+	}
+	return entry.ID
+}
+
+func (h *Handler) ListHistory(c *gin.Context) {
+	filter := store.HistoryFilter{
+		Search:  c.Query("q"),
+		Profile: c.Query("profile"),
+	}
+	var err error
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.Offset, err = queryInt(c, "offset"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if v := c.Query("success"); v != "" {
+		success, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "success must be true or false"})
+			return
+		}
+		filter.Success = &success
+	}
+
+	entries, total, err := h.history.ListHistory(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": total})
+}
+
+func (h *Handler) GetHistoryEntry(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	entry, err := h.history.GetHistory(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *Handler) DeleteHistoryEntry(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.history.DeleteHistory(c.Request.Context(), id); err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "History entry deleted"})
+}
+
+func (h *Handler) ListSavedQueries(c *gin.Context) {
+	queries, err := h.history.ListSavedQueries(c.Request.Context(), c.Query("profile"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"saved_queries": queries})
+}
+
+func (h *Handler) GetSavedQuery(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	saved, err := h.history.GetSavedQuery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// CreateSavedQuery saves SQL under a name, either given directly or taken
+// from a history entry.
+func (h *Handler) CreateSavedQuery(c *gin.Context) {
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved := &store.SavedQuery{}
+	if req.HistoryID != 0 {
+		entry, err := h.history.GetHistory(c.Request.Context(), req.HistoryID)
+		if err != nil {
+			c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+			return
+		}
+		saved.Name = entry.Question
+		saved.Question = entry.Question
+		saved.SQL = entry.SQL
+		saved.Profile = entry.Profile
+	}
+	req.applyTo(saved)
+
+	if err := h.history.CreateSavedQuery(c.Request.Context(), saved); err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, saved)
+}
+
+// UpdateSavedQuery changes the given fields, e.g. {"pinned": true} or a new
+// name.
+func (h *Handler) UpdateSavedQuery(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	var req savedQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	saved, err := h.history.GetSavedQuery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+	req.applyTo(saved)
+
+	if err := h.history.UpdateSavedQuery(c.Request.Context(), saved); err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+func (h *Handler) DeleteSavedQuery(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.history.DeleteSavedQuery(c.Request.Context(), id); err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved query deleted"})
+}
+
+// RunSavedQuery executes a saved query's SQL on the session's connection
+// without involving the LLM. A query saved for a profile only runs while
+// the session is connected to that profile. The run is cancellable like any
+// other query.
+func (h *Handler) RunSavedQuery(c *gin.Context) {
+	id, ok := paramID(c)
+	if !ok {
+		return
+	}
+
+	saved, err := h.history.GetSavedQuery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(statusForStoreError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	sess := currentSession(c)
+	ctx, queryID, finish, err := h.startQuery(c, sess, c.Query("query_id"))
+	if err != nil {
+		return
+	}
+	defer finish()
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	// Check if database is connected
+	if !sess.connected() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Database not connected. Please connect to a database first.",
+		})
+		return
+	}
+	if saved.Profile != "" && saved.Profile != sess.conn.Profile {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("This query was saved for profile %q; connect to it before running it", saved.Profile),
+		})
+		return
+	}
+
+	start := time.Now()
+	results, runErr := sess.agent.ExecuteSQL(ctx, saved.SQL)
+
+	response := &agent.AgentResponse{
+		SQL:       saved.SQL,
+		Results:   results,
+		Reasoning: make([]agent.ReasoningStep, 0),
+	}
+	if runErr == nil {
+		response.Success = true
+	} else if ctx.Err() == nil {
+		// Validation or SQL errors are part of the response, not a failed run
+		response.Error = runErr.Error()
+		runErr = nil
+	}
+
+	question := saved.Question
+	if question == "" {
+		question = saved.Name
+	}
+	response.HistoryID = h.recordQuery(sess, store.SourceSavedQuery, question, response, runErr, time.Since(start))
+	response.ResultID = sess.results.add(response)
+
+	if runErr != nil {
+		status, message := queryErrorStatus(runErr)
+		c.JSON(status, gin.H{"error": message, "query_id": queryID})
+		return
+	}
+
+	if response.Success {
+		if err := h.history.MarkSavedQueryRun(c.Request.Context(), saved.ID); err != nil {
+			log.Printf("Failed to update saved query %d: %v", saved.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func paramID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return id, true
+}
+
+func queryInt(c *gin.Context, key string) (int, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, errors.New(key + " must be a non-negative integer")
+	}
+	return n, nil
+}
+
+func statusForStoreError(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrInvalid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
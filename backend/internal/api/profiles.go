@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gibranda/chat-with-database/internal/profiles"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) ListProfiles(c *gin.Context) {
+	list := h.profiles.List()
+	redacted := make([]profiles.Profile, 0, len(list))
+	for _, p := range list {
+		redacted = append(redacted, p.Redacted())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profiles": redacted,
+		"default":  h.config.Connections.Default,
+	})
+}
+
+func (h *Handler) GetProfile(c *gin.Context) {
+	profile, err := h.profiles.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile.Redacted())
+}
+
+func (h *Handler) CreateProfile(c *gin.Context) {
+	var profile profiles.Profile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.profiles.Create(profile); err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	created, _ := h.profiles.Get(profile.Name)
+	c.JSON(http.StatusCreated, created.Redacted())
+}
+
+// UpdateProfile replaces a stored profile; omitting the password keeps the
+// existing one.
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	var profile profiles.Profile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile.Name = c.Param("name")
+
+	if err := h.profiles.Update(profile); err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, _ := h.profiles.Get(profile.Name)
+	c.JSON(http.StatusOK, updated.Redacted())
+}
+
+func (h *Handler) DeleteProfile(c *gin.Context) {
+	if err := h.profiles.Delete(c.Param("name")); err != nil {
+		c.JSON(statusForProfileError(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Profile deleted"})
+}
+
+func statusForProfileError(err error) int {
+	switch {
+	case errors.Is(err, profiles.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, profiles.ErrExists), errors.Is(err, profiles.ErrReadOnly):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
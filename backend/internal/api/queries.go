@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	QueryIDHeader = "X-Query-ID"
+
+	// statusClientClosedRequest is reported when a run was cancelled before
+	// it finished.
+	statusClientClosedRequest = 499
+)
+
+var (
+	ErrQueryIDInUse = errors.New("query id is already in use")
+
+	validQueryID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type runningQuery struct {
+	sessionID string
+	cancel    context.CancelFunc
+}
+
+// QueryRegistry tracks in-flight runs so they can be cancelled from another
+// request. It is separate from the session lock, which a run holds for its
+// whole duration.
+type QueryRegistry struct {
+	mu   sync.Mutex
+	runs map[string]*runningQuery
+}
+
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{runs: make(map[string]*runningQuery)}
+}
+
+// Start registers a run for the session under id (generating one when id
+// is empty) and returns a context that is cancelled by Cancel, by parent,
+// or after timeout. finish must be called once the run is over.
+func (r *QueryRegistry) Start(parent context.Context, sessionID, id string, timeout time.Duration) (ctx context.Context, queryID string, finish func(), err error) {
+	if id == "" {
+		if id, err = newQueryID(); err != nil {
+			return nil, "", nil, err
+		}
+	} else if !validQueryID.MatchString(id) {
+		return nil, "", nil, errors.New("invalid query id: use up to 64 letters, digits, '_' or '-'")
+	}
+
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.runs[id]; exists {
+		cancel()
+		return nil, "", nil, ErrQueryIDInUse
+	}
+	r.runs[id] = &runningQuery{sessionID: sessionID, cancel: cancel}
+
+	finish = func() {
+		cancel()
+		r.mu.Lock()
+		delete(r.runs, id)
+		r.mu.Unlock()
+	}
+	return ctx, id, finish, nil
+}
+
+// Cancel aborts the session's run with the given id, reporting whether it
+// was found.
+func (r *QueryRegistry) Cancel(sessionID, id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok || run.sessionID != sessionID {
+		return false
+	}
+	run.cancel()
+	return true
+}
+
+// CancelQuery aborts an in-flight run started by this session, including
+// the SQL statement it is executing.
+func (h *Handler) CancelQuery(c *gin.Context) {
+	sess := currentSession(c)
+	if !h.queries.Cancel(sess.ID, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No running query with this id"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Query cancelled"})
+}
+
+// queryErrorStatus maps a failed run to an HTTP status and message,
+// distinguishing cancellations and timeouts from other failures.
+func queryErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, "Query cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "Query timed out: " + err.Error()
+	default:
+		return http.StatusInternalServerError, err.Error()
+	}
+}
+
+func newQueryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/agent"
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxResultHandles = 20
+	defaultPageSize  = 100
+	maxPageSize      = 1000
+)
+
+// resultHandle remembers the SQL behind a response so its full result can
+// be browsed page by page without asking the LLM again.
+type resultHandle struct {
+	ID        string
+	SQL       string
+	Columns   []database.ResultColumn
+	CreatedAt time.Time
+
+	// total is the row count, once computed
+	total *int64
+}
+
+// resultHandles holds a session's most recent result handles. They are
+// dropped when the session's connection changes. The caller must hold the
+// session lock.
+type resultHandles struct {
+	byID  map[string]*resultHandle
+	order []string
+}
+
+// add registers the SQL and columns of a successful response, returning the
+// handle ID ("" when there is nothing to page through).
+func (r *resultHandles) add(response *agent.AgentResponse) string {
+	if response == nil || !response.Success || response.Results == nil || strings.HasPrefix(response.SQL, "--") {
+		return ""
+	}
+	id, err := newQueryID()
+	if err != nil {
+		return ""
+	}
+
+	if r.byID == nil {
+		r.byID = make(map[string]*resultHandle)
+	}
+	if len(r.order) >= maxResultHandles {
+		delete(r.byID, r.order[0])
+		r.order = r.order[1:]
+	}
+	r.byID[id] = &resultHandle{
+		ID:        id,
+		SQL:       response.SQL,
+		Columns:   response.Results.Columns,
+		CreatedAt: time.Now(),
+	}
+	r.order = append(r.order, id)
+	return id
+}
+
+func (r *resultHandles) get(id string) (*resultHandle, bool) {
+	handle, ok := r.byID[id]
+	return handle, ok
+}
+
+// pageCursor is the decoded form of the opaque cursor handed to clients.
+// It carries either the next offset or, for keyset paging, the key columns
+// and the key values of the last row returned.
+type pageCursor struct {
+	Offset int           `json:"o,omitempty"`
+	Keys   []string      `json:"k,omitempty"`
+	Desc   bool          `json:"d,omitempty"`
+	After  []interface{} `json:"a,omitempty"`
+}
+
+func (pc pageCursor) encode() string {
+	b, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var pc pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pc, errors.New("invalid cursor")
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&pc); err != nil {
+		return pc, errors.New("invalid cursor")
+	}
+	return pc, nil
+}
+
+// GetResultPage re-runs a result's SQL for one page of rows. Offset paging
+// uses ?offset=&limit=. Keyset paging orders by ?key=col1,col2 (optionally
+// ?desc=true) and continues from ?cursor=, the next_cursor of the previous
+// page; key columns should be unique and non-NULL. The total row count is
+// included once computed by GET /api/results/:id/count.
+func (h *Handler) GetResultPage(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	var cursor pageCursor
+	if v := c.Query("cursor"); v != "" {
+		if cursor, err = decodeCursor(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		if cursor.Offset, err = queryInt(c, "offset"); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if key := c.Query("key"); key != "" {
+			cursor.Keys = strings.Split(key, ",")
+			cursor.Desc, _ = strconv.ParseBool(c.Query("desc"))
+		}
+	}
+
+	sess := currentSession(c)
+	ctx, queryID, finish, err := h.startQuery(c, sess, c.Query("query_id"))
+	if err != nil {
+		return
+	}
+	defer finish()
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	handle, ok := sess.results.get(c.Param("id"))
+	if !ok || !sess.connected() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found; it may have expired or the connection changed"})
+		return
+	}
+
+	page := database.PageRequest{
+		Offset:     cursor.Offset,
+		Limit:      limit,
+		Descending: cursor.Desc,
+		After:      cursor.After,
+	}
+	for _, name := range cursor.Keys {
+		col, err := uniqueColumn(handle.Columns, strings.TrimSpace(name))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		page.Keys = append(page.Keys, col)
+	}
+
+	result, hasMore, err := sess.db.FetchPage(ctx, handle.SQL, page)
+	if err != nil {
+		status, message := queryErrorStatus(err)
+		c.JSON(status, gin.H{"error": message, "query_id": queryID})
+		return
+	}
+
+	response := gin.H{
+		"result_id": handle.ID,
+		"columns":   result.Columns,
+		"rows":      result.Rows,
+		"count":     result.Count,
+		"limit":     limit,
+		"has_more":  hasMore,
+	}
+	if len(cursor.After) == 0 {
+		response["offset"] = cursor.Offset
+	}
+	if handle.total != nil {
+		response["total"] = *handle.total
+	}
+	if hasMore {
+		next := pageCursor{Offset: cursor.Offset + result.Count}
+		if len(page.Keys) > 0 {
+			// Continue after the last row's key instead of counting rows
+			next = pageCursor{Keys: cursor.Keys, Desc: cursor.Desc, After: keyValues(result, page.Keys)}
+		}
+		response["next_cursor"] = next.encode()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CountResultRows computes (once) the total number of rows in a result.
+func (h *Handler) CountResultRows(c *gin.Context) {
+	sess := currentSession(c)
+	ctx, queryID, finish, err := h.startQuery(c, sess, c.Query("query_id"))
+	if err != nil {
+		return
+	}
+	defer finish()
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	handle, ok := sess.results.get(c.Param("id"))
+	if !ok || !sess.connected() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Result not found; it may have expired or the connection changed"})
+		return
+	}
+
+	if handle.total == nil {
+		total, err := sess.db.CountRows(ctx, handle.SQL)
+		if err != nil {
+			status, message := queryErrorStatus(err)
+			c.JSON(status, gin.H{"error": message, "query_id": queryID})
+			return
+		}
+		handle.total = &total
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result_id": handle.ID, "total": *handle.total})
+}
+
+// uniqueColumn finds the column called name, which must not be repeated in
+// the result.
+func uniqueColumn(columns []database.ResultColumn, name string) (database.ResultColumn, error) {
+	var found *database.ResultColumn
+	for i := range columns {
+		if columns[i].Name != name {
+			continue
+		}
+		if found != nil {
+			return database.ResultColumn{}, errors.New("key column " + strconv.Quote(name) + " is ambiguous")
+		}
+		found = &columns[i]
+	}
+	if found == nil {
+		return database.ResultColumn{}, errors.New("unknown key column " + strconv.Quote(name))
+	}
+	return *found, nil
+}
+
+// keyValues returns the key columns' values in the last row of result.
+func keyValues(result *database.QueryResult, keys []database.ResultColumn) []interface{} {
+	last := result.Rows[len(result.Rows)-1]
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		for j, col := range result.Columns {
+			if col.Name == key.Name {
+				values[i] = last[j]
+				break
+			}
+		}
+	}
+	return values
+}
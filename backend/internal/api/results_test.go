@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []pageCursor{
+		{},
+		{Offset: 200},
+		{Keys: []string{"id"}, After: []interface{}{json.Number("42")}},
+		{Keys: []string{"created_at", "id"}, Desc: true, After: []interface{}{"2024-05-01T10:00:00Z", json.Number("9007199254740993")}},
+		{Keys: []string{"price"}, After: []interface{}{json.Number("12.50")}},
+		{Keys: []string{"name"}, After: []interface{}{"O'Brien / \"x\""}},
+	}
+
+	for _, want := range tests {
+		encoded := want.encode()
+		got, err := decodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("decodeCursor(%q) error = %v", encoded, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeCursor(encode(%+v)) = %+v", want, got)
+		}
+	}
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm90IGpzb24", "W10"} {
+		if _, err := decodeCursor(s); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded", s)
+		}
+	}
+}
@@ -16,27 +16,60 @@ func SetupRouter(handler *Handler, debug bool) *gin.Engine {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", SessionHeader}
+	config.ExposeHeaders = []string{SessionHeader, QueryIDHeader, "Content-Disposition"}
 	router.Use(cors.New(config))
 
-	// API routes
+	router.GET("/api/health", handler.Health)
+
+	// API routes, each scoped to the caller's session
 	api := router.Group("/api")
+	api.Use(handler.sessionMiddleware)
 	{
-		api.GET("/health", handler.Health)
-		
+
 		// Connection management
 		api.POST("/connection/test", handler.TestConnection)
 		api.POST("/connection/connect", handler.Connect)
 		api.POST("/connection/disconnect", handler.Disconnect)
 		api.GET("/connection/status", handler.GetConnectionStatus)
+
+		// Connection profiles
+		api.GET("/connection/profiles", handler.ListProfiles)
+		api.POST("/connection/profiles", handler.CreateProfile)
+		api.GET("/connection/profiles/:name", handler.GetProfile)
+		api.PUT("/connection/profiles/:name", handler.UpdateProfile)
+		api.DELETE("/connection/profiles/:name", handler.DeleteProfile)
 		
 		// Query and schema
 		api.POST("/query", handler.Query)
+		api.POST("/query/stream", handler.QueryStream)
+		api.POST("/query/:id/cancel", handler.CancelQuery)
 		api.GET("/schema", handler.GetSchema)
 		api.POST("/schema/refresh", handler.RefreshSchema)
+		api.GET("/schema/descriptions", handler.ListDescriptions)
+		api.PUT("/schema/descriptions", handler.SetDescription)
 		api.GET("/tables", handler.GetTables)
 		api.GET("/tables/:table", handler.GetTableInfo)
+		api.GET("/tables/:table/profile", handler.GetTableProfile)
 		api.POST("/history/clear", handler.ClearHistory)
+
+		// Persistent query history and saved queries
+		api.GET("/history", handler.ListHistory)
+		api.GET("/history/:id", handler.GetHistoryEntry)
+		api.DELETE("/history/:id", handler.DeleteHistoryEntry)
+		api.GET("/saved-queries", handler.ListSavedQueries)
+		api.POST("/saved-queries", handler.CreateSavedQuery)
+		api.GET("/saved-queries/:id", handler.GetSavedQuery)
+		api.PUT("/saved-queries/:id", handler.UpdateSavedQuery)
+		api.DELETE("/saved-queries/:id", handler.DeleteSavedQuery)
+		api.POST("/saved-queries/:id/run", handler.RunSavedQuery)
+
+		// Full result downloads
+		api.GET("/export", handler.Export)
+
+		// Paging through the full result of an executed query
+		api.GET("/results/:id", handler.GetResultPage)
+		api.GET("/results/:id/count", handler.CountResultRows)
 	}
 
 	return router
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/agent"
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SessionHeader = "X-Session-ID"
+	SessionCookie = "session_id"
+
+	defaultMaxSessions        = 50
+	defaultSessionIdleTimeout = 30 * time.Minute
+	sessionContextKey         = "session"
+)
+
+var ErrTooManySessions = errors.New("too many active sessions, please try again later")
+
+// Session is one client's connection, agent and conversation history.
+// mu serializes requests within the session; the agent is not safe for
+// concurrent use.
+type Session struct {
+	ID string
+
+	mu       sync.Mutex
+	db       *database.Database
+	agent    *agent.Agent
+	conn     connectionInfo
+	results  resultHandles
+	lastSeen time.Time
+
+	// descriptionsVersion is the version of the description overrides
+	// loaded into db; see descriptionVersions
+	descriptionsVersion uint64
+
+	// hasDB mirrors db != nil so health checks need not wait for mu
+	hasDB atomic.Bool
+}
+
+// connectionInfo describes what a session is connected to, for history.
+type connectionInfo struct {
+	Profile  string
+	Type     string
+	Database string
+}
+
+// key identifies the connection for locally stored schema descriptions:
+// the profile name, or the database type and name for ad hoc connections.
+func (c connectionInfo) key() string {
+	if c.Profile != "" {
+		return "profile:" + c.Profile
+	}
+	return c.Type + ":" + c.Database
+}
+
+func (s *Session) connected() bool {
+	return s.db != nil && s.agent != nil
+}
+
+// setConnection replaces the session's database and agent, closing the
+// previous connection. The caller must hold s.mu.
+func (s *Session) setConnection(db *database.Database, agentInstance *agent.Agent) {
+	if s.db != nil {
+		s.db.Close()
+	}
+	s.db = db
+	s.agent = agentInstance
+	s.conn = connectionInfo{}
+	s.results = resultHandles{}
+	s.hasDB.Store(db != nil)
+}
+
+// SessionManager owns all sessions, keyed by an opaque token sent back to
+// the client in the X-Session-ID header and a cookie.
+type SessionManager struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	maxSessions int
+	idleTimeout time.Duration
+}
+
+func NewSessionManager(maxSessions int, idleTimeout time.Duration) *SessionManager {
+	if maxSessions <= 0 {
+		maxSessions = defaultMaxSessions
+	}
+	if idleTimeout <= 0 {
+		idleTimeout = defaultSessionIdleTimeout
+	}
+
+	m := &SessionManager{
+		sessions:    make(map[string]*Session),
+		maxSessions: maxSessions,
+		idleTimeout: idleTimeout,
+	}
+	go m.reapLoop()
+	return m
+}
+
+// Get returns the session for id, refreshing its idle timer.
+func (m *SessionManager) Get(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[id]
+	if ok {
+		s.lastSeen = time.Now()
+	}
+	return s, ok
+}
+
+// Create starts a new session, expiring idle ones first if at the cap.
+func (m *SessionManager) Create() (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.sessions) >= m.maxSessions {
+		m.reapLocked()
+		if len(m.sessions) >= m.maxSessions {
+			return nil, ErrTooManySessions
+		}
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{ID: id, lastSeen: time.Now()}
+	m.sessions[id] = s
+	return s, nil
+}
+
+// Count returns the number of live sessions.
+func (m *SessionManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func (m *SessionManager) reapLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.mu.Lock()
+		m.reapLocked()
+		m.mu.Unlock()
+	}
+}
+
+// reapLocked closes sessions idle for longer than the timeout, skipping
+// sessions with a request in flight. The caller must hold m.mu.
+func (m *SessionManager) reapLocked() {
+	cutoff := time.Now().Add(-m.idleTimeout)
+	for id, s := range m.sessions {
+		if s.lastSeen.After(cutoff) || !s.mu.TryLock() {
+			continue
+		}
+		s.setConnection(nil, nil)
+		s.mu.Unlock()
+		delete(m.sessions, id)
+		log.Printf("Session %s expired", id[:8])
+	}
+}
+
+// sessionMiddleware attaches the caller's session to the request, creating
+// one when the client has none (or an expired one).
+func (h *Handler) sessionMiddleware(c *gin.Context) {
+	s, ok := h.lookupSession(c)
+	if !ok {
+		var err error
+		s, err = h.sessions.Create()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		h.attachDefaultProfile(c.Request.Context(), s)
+	}
+
+	c.Set(sessionContextKey, s)
+	c.Header(SessionHeader, s.ID)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookie, s.ID, int(h.sessions.idleTimeout.Seconds()), "/", "", false, true)
+	c.Next()
+}
+
+// attachDefaultProfile connects a new session to the configured default
+// profile, if any. Failures are logged and leave the session disconnected.
+func (h *Handler) attachDefaultProfile(ctx context.Context, s *Session) {
+	name := h.config.Connections.Default
+	if name == "" {
+		return
+	}
+
+	profile, err := h.profiles.Get(name)
+	if err != nil {
+		log.Printf("Default profile %q unavailable: %v", name, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := h.connectSession(ctx, s, name, profile.DatabaseConfig()); err != nil {
+		log.Printf("Failed to auto-connect session %s to profile %q: %v", s.ID[:8], name, err)
+	}
+}
+
+// lookupSession finds an existing session from the header or cookie without
+// creating one.
+func (h *Handler) lookupSession(c *gin.Context) (*Session, bool) {
+	id := c.GetHeader(SessionHeader)
+	if id == "" {
+		id, _ = c.Cookie(SessionCookie)
+	}
+	if id == "" {
+		return nil, false
+	}
+	return h.sessions.Get(id)
+}
+
+func currentSession(c *gin.Context) *Session {
+	return c.MustGet(sessionContextKey).(*Session)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -5,13 +5,19 @@ import (
 	"os"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/gibranda/chat-with-database/internal/database"
 )
 
 type Config struct {
-	Database DatabaseConfig `yaml:"database"`
-	Ollama   OllamaConfig   `yaml:"ollama"`
-	Server   ServerConfig   `yaml:"server"`
-	Agent    AgentConfig    `yaml:"agent"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Connections ConnectionsConfig `yaml:"connections"`
+	Ollama      OllamaConfig      `yaml:"ollama"`
+	LLM         LLMConfig         `yaml:"llm"`
+	Server      ServerConfig      `yaml:"server"`
+	Agent       AgentConfig       `yaml:"agent"`
+	Timeouts    TimeoutsConfig    `yaml:"timeouts"`
+	Storage     StorageConfig     `yaml:"storage"`
 }
 
 type DatabaseConfig struct {
@@ -23,26 +29,80 @@ type DatabaseConfig struct {
 	Password string `yaml:"password"`
 	SSLMode  string `yaml:"sslmode"`
 	Path     string `yaml:"path"` // For SQLite
+
+	// Postgres schemas / MySQL databases to introspect (names or globs)
+	Schemas        []string `yaml:"schemas"`
+	ExcludeSchemas []string `yaml:"exclude_schemas"`
+}
+
+// ConnectionsConfig holds named connection profiles. Profiles defined here
+// are read-only; profiles created through the API are kept in StorePath
+// with their passwords encrypted using Secret.
+type ConnectionsConfig struct {
+	Default   string                    `yaml:"default"` // profile attached to every new session
+	StorePath string                    `yaml:"store_path"`
+	Secret    string                    `yaml:"secret"`
+	Profiles  map[string]DatabaseConfig `yaml:"profiles"`
 }
 
 type OllamaConfig struct {
-	Host        string  `yaml:"host"`
-	Model       string  `yaml:"model"`
-	Temperature float64 `yaml:"temperature"`
-	Timeout     int     `yaml:"timeout"`
+	Host           string  `yaml:"host"`
+	Model          string  `yaml:"model"`
+	EmbeddingModel string  `yaml:"embedding_model"`
+	Temperature    float64 `yaml:"temperature"`
+	Timeout        int     `yaml:"timeout"`
+}
+
+// LLMConfig selects the LLM provider. Empty fields fall back to the
+// legacy ollama section so existing config files keep working.
+type LLMConfig struct {
+	Provider       string  `yaml:"provider"` // ollama, openai
+	Host           string  `yaml:"host"`
+	Model          string  `yaml:"model"`
+	EmbeddingModel string  `yaml:"embedding_model"` // for schema retrieval; empty uses keyword matching only
+	APIKey         string  `yaml:"api_key"`
+	Temperature    float64 `yaml:"temperature"`
+	Timeout        int     `yaml:"timeout"`
 }
 
 type ServerConfig struct {
-	Port  int    `yaml:"port"`
-	Host  string `yaml:"host"`
-	Debug bool   `yaml:"debug"`
+	Port               int    `yaml:"port"`
+	Host               string `yaml:"host"`
+	Debug              bool   `yaml:"debug"`
+	MaxSessions        int    `yaml:"max_sessions"`
+	SessionIdleTimeout int    `yaml:"session_idle_timeout"` // seconds
 }
 
 type AgentConfig struct {
-	MaxIterations         int  `yaml:"max_iterations"`
-	EnableQueryValidation bool `yaml:"enable_query_validation"`
-	ReadonlyMode          bool `yaml:"readonly_mode"`
-	MaxResults            int  `yaml:"max_results"`
+	MaxIterations         int    `yaml:"max_iterations"`
+	EnableQueryValidation bool   `yaml:"enable_query_validation"`
+	ReadonlyMode          bool   `yaml:"readonly_mode"`
+	MaxResults            int    `yaml:"max_results"`
+	HistoryWindow         int    `yaml:"history_window"`       // previous turns included in prompts
+	HistoryTokenBudget    int    `yaml:"history_token_budget"` // approximate token cap for those turns
+	RewriteFollowUps      bool   `yaml:"rewrite_follow_ups"`
+	ProfileColumns        bool   `yaml:"profile_columns"`   // sample column values into SQL prompts
+	SchemaTopK            int    `yaml:"schema_top_k"`      // tables kept when pruning large schemas; 0 disables pruning
+	SchemaMaxChars        int    `yaml:"schema_max_chars"`  // schema summaries longer than this are pruned
+	Language              string `yaml:"language"`          // answer language code or name, e.g. en, id
+	PromptsDir            string `yaml:"prompts_dir"`       // directory of prompt template and message overrides
+	IntentClassifier      string `yaml:"intent_classifier"` // llm (default) or rules
+	SQLOutput             string `yaml:"sql_output"`        // json (default) or text
+	ClarifyQuestions      bool   `yaml:"clarify_questions"` // ask back instead of guessing on ambiguous questions
+}
+
+// TimeoutsConfig bounds each stage of answering a question, in seconds.
+// Zero means no limit for that stage.
+type TimeoutsConfig struct {
+	Query   int `yaml:"query"`    // whole question, across every LLM call and SQL statement
+	LLMCall int `yaml:"llm_call"` // each LLM request
+	SQL     int `yaml:"sql"`      // each SQL statement
+}
+
+// StorageConfig locates the embedded SQLite file holding query history and
+// saved queries.
+type StorageConfig struct {
+	Path string `yaml:"path"`
 }
 
 func Load(path string) (*Config, error) {
@@ -56,20 +116,70 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	cfg.applyLLMDefaults()
+	cfg.applyConnectionDefaults()
+
+	if cfg.Storage.Path == "" {
+		cfg.Storage.Path = "history.db"
+	}
+
 	return &cfg, nil
 }
 
 func (c *DatabaseConfig) ConnectionString() string {
 	switch c.Type {
 	case "postgres":
-		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-			c.Host, c.Port, c.User, c.Password, c.Name, c.SSLMode)
+		sslmode := c.SSLMode
+		if sslmode == "" {
+			sslmode = "disable"
+		}
+		return database.BuildPostgresConnString(c.Host, c.Port, c.Name, c.User, c.Password, sslmode)
 	case "mysql":
-		return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
-			c.User, c.Password, c.Host, c.Port, c.Name)
-	case "sqlite":
+		return database.BuildMySQLConnString(c.Host, c.Port, c.Name, c.User, c.Password)
+	case "sqlite", "sqlite3":
 		return c.Path
 	default:
 		return ""
 	}
 }
+
+func (c *Config) applyLLMDefaults() {
+	if c.LLM.Provider == "" {
+		c.LLM.Provider = "ollama"
+	}
+	if c.LLM.Host == "" {
+		c.LLM.Host = c.Ollama.Host
+	}
+	if c.LLM.Model == "" {
+		c.LLM.Model = c.Ollama.Model
+	}
+	if c.LLM.EmbeddingModel == "" {
+		c.LLM.EmbeddingModel = c.Ollama.EmbeddingModel
+	}
+	if c.LLM.Temperature == 0 {
+		c.LLM.Temperature = c.Ollama.Temperature
+	}
+	if c.LLM.Timeout == 0 {
+		c.LLM.Timeout = c.Ollama.Timeout
+	}
+	if c.LLM.APIKey == "" {
+		c.LLM.APIKey = os.Getenv("LLM_API_KEY")
+	}
+}
+
+// applyConnectionDefaults exposes the legacy database section as a profile
+// named "default" and resolves the profile store settings.
+func (c *Config) applyConnectionDefaults() {
+	if c.Connections.Profiles == nil {
+		c.Connections.Profiles = make(map[string]DatabaseConfig)
+	}
+	if _, exists := c.Connections.Profiles["default"]; !exists && c.Database.Type != "" {
+		c.Connections.Profiles["default"] = c.Database
+	}
+	if c.Connections.StorePath == "" {
+		c.Connections.StorePath = "profiles.json"
+	}
+	if c.Connections.Secret == "" {
+		c.Connections.Secret = os.Getenv("PROFILE_SECRET")
+	}
+}
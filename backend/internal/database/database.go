@@ -1,10 +1,14 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gibranda/chat-with-database/internal/sqlguard"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -29,57 +33,86 @@ func BuildMySQLConnString(host string, port int, dbname, user, password string)
 }
 
 type Database struct {
-	db     *sql.DB
-	dbType string
+	db             *sql.DB
+	dbType         string
+	readOnly       bool
+	queryTimeout   time.Duration
+	schemas        []string
+	excludeSchemas []string
+
+	// mu guards descriptions, the overrides keyed by descriptionKey, and
+	// the cached table profiles
+	mu           sync.Mutex
+	descriptions map[string]string
+	profiles     map[TableName]*TableProfile
 }
 
-type TableInfo struct {
-	Name        string   `json:"name"`
-	Columns     []Column `json:"columns"`
-	RowCount    int64    `json:"row_count"`
-	Description string   `json:"description,omitempty"`
-}
-
-type Column struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Nullable   bool   `json:"nullable"`
-	PrimaryKey bool   `json:"primary_key"`
-	ForeignKey string `json:"foreign_key,omitempty"`
+// Options tunes how a Database is opened.
+type Options struct {
+	// ReadOnly runs agent-issued queries inside read-only transactions and
+	// opens SQLite files with mode=ro and the query_only pragma, so safety
+	// does not depend on inspecting the SQL text.
+	ReadOnly bool
+
+	// QueryTimeout bounds each agent-issued statement; zero means no limit
+	// beyond the caller's context.
+	QueryTimeout time.Duration
+
+	// Schemas limits introspection to these Postgres schemas or MySQL
+	// databases, and ExcludeSchemas skips some; both take exact names or
+	// path.Match patterns. By default Postgres covers every non-system
+	// schema and MySQL the connection's database.
+	Schemas        []string
+	ExcludeSchemas []string
 }
 
+// QueryResult holds rows as arrays in column order, so duplicate column
+// names (SELECT a.id, b.id) survive. Values are already in their JSON form;
+// see ResultColumn.JSONValue. Limit is the row cap that was applied (0 for
+// none) and Truncated reports whether the query had more rows than that.
 type QueryResult struct {
-	Columns []string                 `json:"columns"`
-	Rows    []map[string]interface{} `json:"rows"`
-	Count   int                      `json:"count"`
+	Columns   []ResultColumn  `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Count     int             `json:"count"`
+	Limit     int             `json:"limit,omitempty"`
+	Truncated bool            `json:"truncated"`
 }
 
-type SchemaInfo struct {
-	Tables        []TableInfo           `json:"tables"`
-	Relationships []TableRelationship   `json:"relationships"`
-	Summary       string                `json:"summary"`
+// ColumnNames returns the names of the result's columns in order.
+func (r *QueryResult) ColumnNames() []string {
+	names := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		names[i] = col.Name
+	}
+	return names
 }
 
-type TableRelationship struct {
-	FromTable  string `json:"from_table"`
-	FromColumn string `json:"from_column"`
-	ToTable    string `json:"to_table"`
-	ToColumn   string `json:"to_column"`
+func New(ctx context.Context, dbType, connectionString string) (*Database, error) {
+	return Open(ctx, dbType, connectionString, Options{})
 }
 
-func New(dbType, connectionString string) (*Database, error) {
+func Open(ctx context.Context, dbType, connectionString string, opts Options) (*Database, error) {
+	if opts.ReadOnly && dbType == "sqlite3" {
+		connectionString = sqliteReadOnlyDSN(connectionString)
+	}
+
 	db, err := sql.Open(dbType, connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	return &Database{
-		db:     db,
-		dbType: dbType,
+		db:             db,
+		dbType:         dbType,
+		readOnly:       opts.ReadOnly,
+		queryTimeout:   opts.QueryTimeout,
+		schemas:        opts.Schemas,
+		excludeSchemas: opts.ExcludeSchemas,
 	}, nil
 }
 
@@ -87,238 +120,228 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-func (d *Database) GetTables() ([]string, error) {
-	var query string
+// ReadOnly reports whether agent-issued queries run in read-only sessions.
+func (d *Database) ReadOnly() bool {
+	return d.readOnly
+}
+
+// sqliteReadOnlyDSN turns a SQLite path or file: URI into a read-only URI.
+func sqliteReadOnlyDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "mode=ro&_query_only=true"
+}
+
+// beginReadOnly starts a transaction on conn in which the server rejects
+// writes.
+func (d *Database) beginReadOnly(ctx context.Context, conn *sql.Conn) (*sql.Tx, error) {
 	switch d.dbType {
 	case "postgres":
-		query = `
-			SELECT table_name 
-			FROM information_schema.tables 
-			WHERE table_schema = 'public' 
-			AND table_type = 'BASE TABLE'
-			ORDER BY table_name
-		`
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		return tx, nil
 	case "mysql":
-		query = `
-			SELECT table_name 
-			FROM information_schema.tables 
-			WHERE table_schema = DATABASE()
-			AND table_type = 'BASE TABLE'
-			ORDER BY table_name
-		`
-	case "sqlite3":
-		query = `
-			SELECT name 
-			FROM sqlite_master 
-			WHERE type='table' 
-			AND name NOT LIKE 'sqlite_%'
-			ORDER BY name
-		`
+		// The driver issues START TRANSACTION READ ONLY
+		return conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
 	default:
-		return nil, fmt.Errorf("unsupported database type: %s", d.dbType)
+		// SQLite connections are already opened with mode=ro and query_only
+		return conn.BeginTx(ctx, nil)
 	}
+}
 
-	rows, err := d.db.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query tables: %w", err)
+// runQuery executes an agent-issued query with args and hands the rows to
+// fn, which must consume them before returning. In read-only mode the query
+// runs in a read-only transaction that is always rolled back, and must be a
+// single statement: a second one could COMMIT and run outside the
+// transaction. Cancelling ctx (or hitting the query timeout) stops the
+// statement on the server.
+func (d *Database) runQuery(ctx context.Context, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	if d.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.queryTimeout)
+		defer cancel()
 	}
-	defer rows.Close()
+	return d.runUntimed(ctx, query, args, fn)
+}
 
-	var tables []string
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, err
+// runUntimed is runQuery without the query timeout, for callers that bound
+// the statement themselves.
+func (d *Database) runUntimed(ctx context.Context, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	if d.readOnly {
+		if err := sqlguard.SingleStatement(d.dbType, query); err != nil {
+			return fmt.Errorf("read-only mode: %w", err)
 		}
-		tables = append(tables, tableName)
 	}
 
-	return tables, nil
+	err := d.runOnConn(ctx, query, args, fn)
+	if err != nil && ctx.Err() != nil {
+		// Report why the statement was stopped, not the driver's reaction to it
+		return fmt.Errorf("query stopped: %w", context.Cause(ctx))
+	}
+	return err
 }
 
-func (d *Database) GetTableInfo(tableName string) (*TableInfo, error) {
-	columns, err := d.getColumns(tableName)
+func (d *Database) runOnConn(ctx context.Context, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	conn, err := d.db.Conn(ctx)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to acquire connection: %w", err)
 	}
+	defer conn.Close()
 
-	rowCount, err := d.getRowCount(tableName)
-	if err != nil {
-		rowCount = 0 // Non-critical error
+	if d.dbType == "mysql" {
+		stop, err := d.killOnCancel(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer stop()
 	}
 
-	return &TableInfo{
-		Name:     tableName,
-		Columns:  columns,
-		RowCount: rowCount,
-	}, nil
-}
+	if !d.readOnly {
+		rows, err := conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		return fn(rows)
+	}
 
-func (d *Database) getColumns(tableName string) ([]Column, error) {
-	var query string
-	switch d.dbType {
-	case "postgres":
-		query = fmt.Sprintf(`
-			SELECT 
-				c.column_name,
-				c.data_type,
-				c.is_nullable,
-				CASE WHEN pk.column_name IS NOT NULL THEN true ELSE false END as is_primary,
-				COALESCE(fk.foreign_table || '.' || fk.foreign_column, '') as foreign_key
-			FROM information_schema.columns c
-			LEFT JOIN (
-				SELECT ku.column_name
-				FROM information_schema.table_constraints tc
-				JOIN information_schema.key_column_usage ku
-					ON tc.constraint_name = ku.constraint_name
-				WHERE tc.table_name = '%s'
-				AND tc.constraint_type = 'PRIMARY KEY'
-			) pk ON c.column_name = pk.column_name
-			LEFT JOIN (
-				SELECT
-					kcu.column_name,
-					ccu.table_name AS foreign_table,
-					ccu.column_name AS foreign_column
-				FROM information_schema.table_constraints AS tc
-				JOIN information_schema.key_column_usage AS kcu
-					ON tc.constraint_name = kcu.constraint_name
-				JOIN information_schema.constraint_column_usage AS ccu
-					ON ccu.constraint_name = tc.constraint_name
-				WHERE tc.table_name = '%s'
-				AND tc.constraint_type = 'FOREIGN KEY'
-			) fk ON c.column_name = fk.column_name
-			WHERE c.table_name = '%s'
-			ORDER BY c.ordinal_position
-		`, tableName, tableName, tableName)
-	case "mysql":
-		query = fmt.Sprintf(`
-			SELECT 
-				c.column_name,
-				c.data_type,
-				c.is_nullable,
-				CASE WHEN c.column_key = 'PRI' THEN true ELSE false END as is_primary,
-				COALESCE(
-					CONCAT(
-						k.referenced_table_name, 
-						'.', 
-						k.referenced_column_name
-					), 
-					''
-				) as foreign_key
-			FROM information_schema.columns c
-			LEFT JOIN information_schema.key_column_usage k
-				ON c.table_name = k.table_name
-				AND c.column_name = k.column_name
-				AND k.referenced_table_name IS NOT NULL
-			WHERE c.table_name = '%s'
-			AND c.table_schema = DATABASE()
-			ORDER BY c.ordinal_position
-		`, tableName)
-	case "sqlite3":
-		query = fmt.Sprintf("PRAGMA table_info(%s)", tableName)
-	default:
-		return nil, fmt.Errorf("unsupported database type: %s", d.dbType)
+	tx, err := d.beginReadOnly(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to start read-only transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	rows, err := d.db.Query(query)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query columns: %w", err)
+		return err
 	}
 	defer rows.Close()
+	return fn(rows)
+}
 
-	var columns []Column
-	if d.dbType == "sqlite3" {
-		for rows.Next() {
-			var cid int
-			var name, colType string
-			var notNull, pk int
-			var dfltValue interface{}
-			
-			if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
-				return nil, err
-			}
-			
-			columns = append(columns, Column{
-				Name:       name,
-				Type:       colType,
-				Nullable:   notNull == 0,
-				PrimaryKey: pk == 1,
-			})
-		}
-	} else {
-		for rows.Next() {
-			var col Column
-			var nullable string
-			
-			if err := rows.Scan(&col.Name, &col.Type, &nullable, &col.PrimaryKey, &col.ForeignKey); err != nil {
-				return nil, err
-			}
-			
-			col.Nullable = nullable == "YES"
-			columns = append(columns, col)
-		}
+// killOnCancel issues KILL QUERY for conn's thread when ctx is cancelled.
+// The MySQL driver only drops the connection, which leaves the statement
+// running on the server. Postgres (lib/pq) and SQLite cancel natively.
+func (d *Database) killOnCancel(ctx context.Context, conn *sql.Conn) (stop func(), err error) {
+	var connID int64
+	if err := conn.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&connID); err != nil {
+		return nil, fmt.Errorf("failed to get connection id: %w", err)
 	}
 
-	return columns, nil
-}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			killCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			d.db.ExecContext(killCtx, fmt.Sprintf("KILL QUERY %d", connID))
+		case <-done:
+		}
+	}()
 
-func (d *Database) getRowCount(tableName string) (int64, error) {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
-	var count int64
-	err := d.db.QueryRow(query).Scan(&count)
-	return count, err
+	return func() {
+		close(done)
+		wg.Wait()
+	}, nil
 }
 
-func (d *Database) ExecuteQuery(query string, maxResults int) (*QueryResult, error) {
-	// Clean and prepare query
-	query = strings.TrimSpace(query)
-	
-	// Remove trailing semicolon if present
-	query = strings.TrimRight(query, ";")
-	
-	// Add LIMIT if not present
-	upperQuery := strings.ToUpper(query)
-	if !strings.Contains(upperQuery, "LIMIT") && maxResults > 0 {
-		query = fmt.Sprintf("%s LIMIT %d", query, maxResults)
+// ExecuteQuery runs query and returns at most maxResults rows (all rows when
+// maxResults is 0). A plain SELECT with distinct column names is wrapped as
+// a derived table with an outer LIMIT, so the cap holds whatever LIMIT
+// clauses the statement itself has; other statements (repeated column
+// names, locking reads, SHOW, PRAGMA, ...) run as written and only the
+// first rows are read. One extra row is fetched to tell
+// whether the result was truncated.
+func (d *Database) ExecuteQuery(ctx context.Context, query string, maxResults int) (*QueryResult, error) {
+	query = trimStatement(query)
+	if maxResults > 0 && d.wrappable(query) {
+		query = fmt.Sprintf("SELECT * FROM (\n%s\n) AS _q LIMIT %d", query, maxResults+1)
 	}
-	
-	// Add semicolon back at the end
-	query = query + ";"
 
-	rows, err := d.db.Query(query)
+	var result *QueryResult
+	err := d.runQuery(ctx, query, nil, func(rows *sql.Rows) error {
+		max := 0
+		if maxResults > 0 {
+			max = maxResults + 1
+		}
+		var err error
+		result, err = readResult(rows, max)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
-	defer rows.Close()
 
-	columns, err := rows.Columns()
+	if maxResults > 0 {
+		result.Limit = maxResults
+		if len(result.Rows) > maxResults {
+			result.Rows = result.Rows[:maxResults]
+			result.Count = maxResults
+			result.Truncated = true
+		}
+	}
+	return result, nil
+}
+
+// plainSelect reports whether query is a single read-only SELECT, VALUES or
+// WITH ... SELECT. Locking and INTO clauses are only valid in the outermost
+// query, so they rule out embedding the statement in another one.
+func (d *Database) plainSelect(query string) bool {
+	v := sqlguard.Analyze(d.dbType, query)
+	return v.ReadOnly && v.StatementType == "SELECT"
+}
+
+// wrappable reports whether query can be used as a derived table without
+// changing its result: a plain SELECT whose column names are distinct.
+// MySQL rejects repeated names in a derived table and SQLite renames them.
+func (d *Database) wrappable(query string) bool {
+	return d.plainSelect(query) && sqlguard.DistinctColumnNames(d.dbType, query)
+}
+
+// readResult reads up to max rows (all rows when max is 0) into a
+// QueryResult.
+func readResult(rows *sql.Rows, max int) (*QueryResult, error) {
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
+	columns := ResultColumns(columnTypes)
 
-	var results []map[string]interface{}
-	for rows.Next() {
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
-		}
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
 
+	results := make([][]interface{}, 0)
+	for (max <= 0 || len(results) < max) && rows.Next() {
 		if err := rows.Scan(valuePtrs...); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		row := make(map[string]interface{})
+		row := make([]interface{}, len(columns))
 		for i, col := range columns {
-			val := values[i]
-			if b, ok := val.([]byte); ok {
-				row[col] = string(b)
-			} else {
-				row[col] = val
-			}
+			row[i] = col.JSONValue(values[i])
 		}
 		results = append(results, row)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return &QueryResult{
 		Columns: columns,
@@ -327,9 +350,60 @@ func (d *Database) ExecuteQuery(query string, maxResults int) (*QueryResult, err
 	}, nil
 }
 
+// StreamQuery runs query without adding a LIMIT and hands each row to onRow
+// as it is read, so large results never have to fit in memory. onColumns is
+// called once before the first row. The values slice is reused between
+// rows. The query timeout only applies until the first row arrives; handing
+// the rows to a slow reader is bounded by ctx alone.
+func (d *Database) StreamQuery(ctx context.Context, query string, onColumns func([]ResultColumn) error, onRow func([]interface{}) error) error {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	firstRow := func() {}
+	if d.queryTimeout > 0 {
+		timer := time.AfterFunc(d.queryTimeout, func() {
+			cancel(fmt.Errorf("no rows after %s: %w", d.queryTimeout, context.DeadlineExceeded))
+		})
+		defer timer.Stop()
+		firstRow = func() { timer.Stop() }
+	}
+
+	err := d.runUntimed(ctx, query, nil, func(rows *sql.Rows) error {
+		columnTypes, err := rows.ColumnTypes()
+		if err != nil {
+			return fmt.Errorf("failed to get columns: %w", err)
+		}
+		if err := onColumns(ResultColumns(columnTypes)); err != nil {
+			return err
+		}
+
+		values := make([]interface{}, len(columnTypes))
+		valuePtrs := make([]interface{}, len(columnTypes))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		for rows.Next() {
+			firstRow()
+			if err := rows.Scan(valuePtrs...); err != nil {
+				return fmt.Errorf("failed to scan row: %w", err)
+			}
+			if err := onRow(values); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to execute query: %w", err)
+	}
+	return nil
+}
+
 // ExplainQuery validates the query structure without executing it by using EXPLAIN.
 // This catches missing tables/columns and other structural issues early.
-func (d *Database) ExplainQuery(query string) error {
+func (d *Database) ExplainQuery(ctx context.Context, query string) error {
     q := strings.TrimSpace(query)
     q = strings.TrimRight(q, ";")
 
@@ -342,123 +416,30 @@ func (d *Database) ExplainQuery(query string) error {
         explain = fmt.Sprintf("EXPLAIN %s;", q)
     }
 
-    rows, err := d.db.Query(explain)
+    // EXPLAIN ANALYZE executes the statement, so it gets the same session as queries
+    err := d.runQuery(ctx, explain, nil, func(rows *sql.Rows) error { return nil })
     if err != nil {
         return fmt.Errorf("invalid query: %w", err)
     }
-    defer rows.Close()
     return nil
 }
 
-func (d *Database) GetFullSchema() (*SchemaInfo, error) {
-	tables, err := d.GetTables()
-	if err != nil {
-		return nil, err
-	}
-
-	var tableInfos []TableInfo
-	var relationships []TableRelationship
-
-	for _, tableName := range tables {
-		info, err := d.GetTableInfo(tableName)
-		if err != nil {
-			continue // Skip tables we can't read
-		}
-		tableInfos = append(tableInfos, *info)
-
-		// Extract relationships
-		for _, col := range info.Columns {
-			if col.ForeignKey != "" {
-				parts := strings.Split(col.ForeignKey, ".")
-				if len(parts) == 2 {
-					relationships = append(relationships, TableRelationship{
-						FromTable:  tableName,
-						FromColumn: col.Name,
-						ToTable:    parts[0],
-						ToColumn:   parts[1],
-					})
-				}
-			}
-		}
-	}
-
-	summary := d.generateSchemaSummary(tableInfos, relationships)
-
-	return &SchemaInfo{
-		Tables:        tableInfos,
-		Relationships: relationships,
-		Summary:       summary,
-	}, nil
-}
-
-func (d *Database) generateSchemaSummary(tables []TableInfo, relationships []TableRelationship) string {
-	var sb strings.Builder
-	
-	sb.WriteString(fmt.Sprintf("Database contains %d tables:\n\n", len(tables)))
-	
-	for _, table := range tables {
-		sb.WriteString(fmt.Sprintf("Table: %s (%d rows)\n", table.Name, table.RowCount))
-		sb.WriteString("Columns:\n")
-		for _, col := range table.Columns {
-			markers := []string{}
-			if col.PrimaryKey {
-				markers = append(markers, "PK")
-			}
-			if col.ForeignKey != "" {
-				markers = append(markers, fmt.Sprintf("FK->%s", col.ForeignKey))
-			}
-			if !col.Nullable {
-				markers = append(markers, "NOT NULL")
-			}
-			
-			markerStr := ""
-			if len(markers) > 0 {
-				markerStr = fmt.Sprintf(" [%s]", strings.Join(markers, ", "))
-			}
-			
-			sb.WriteString(fmt.Sprintf("  - %s: %s%s\n", col.Name, col.Type, markerStr))
-		}
-		sb.WriteString("\n")
-	}
-	
-	if len(relationships) > 0 {
-		sb.WriteString(fmt.Sprintf("Relationships (%d):\n", len(relationships)))
-		for _, rel := range relationships {
-			sb.WriteString(fmt.Sprintf("  - %s.%s -> %s.%s\n",
-				rel.FromTable, rel.FromColumn, rel.ToTable, rel.ToColumn))
-		}
-	}
-	
-	return sb.String()
+// AnalyzeSQL classifies query with the SQL tokenizer for this database's
+// dialect.
+func (d *Database) AnalyzeSQL(query string) sqlguard.Verdict {
+	return sqlguard.Analyze(d.dbType, query)
 }
 
+// ValidateSQL rejects queries that are unsafe to run at all: multiple
+// statements, DDL, unbounded UPDATE/DELETE, dangerous functions and file or
+// program access. The returned error is a *sqlguard.Violation.
 func (d *Database) ValidateSQL(query string) error {
-	// Basic SQL injection prevention
-	upperQuery := strings.ToUpper(strings.TrimSpace(query))
-	
-	// Check for dangerous patterns
-	dangerous := []string{
-		"DROP TABLE",
-		"DROP DATABASE",
-		"TRUNCATE",
-		"ALTER TABLE",
-		"CREATE TABLE",
-		"CREATE DATABASE",
-	}
-	
-	for _, pattern := range dangerous {
-		if strings.Contains(upperQuery, pattern) {
-			return fmt.Errorf("potentially dangerous SQL pattern detected: %s", pattern)
-		}
-	}
-	
-	return nil
+	return d.AnalyzeSQL(query).Err()
 }
 
+// IsReadOnlyQuery reports whether query is allowed and neither modifies
+// data (including through data-modifying CTEs) nor takes write locks.
 func (d *Database) IsReadOnlyQuery(query string) bool {
-	upperQuery := strings.ToUpper(strings.TrimSpace(query))
-	return strings.HasPrefix(upperQuery, "SELECT") || 
-	       strings.HasPrefix(upperQuery, "SHOW") ||
-	       strings.HasPrefix(upperQuery, "DESCRIBE") ||
-	       strings.HasPrefix(upperQuery, "EXPLAIN")
+	verdict := d.AnalyzeSQL(query)
+	return verdict.Allowed && verdict.ReadOnly
 }
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyRefusesMultipleStatements(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	rw, err := New(ctx, "sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rw.db.ExecContext(ctx, "CREATE TABLE t (id INTEGER); INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+	rw.Close()
+
+	db, err := Open(ctx, "sqlite3", path, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Even harmless statements are refused: on Postgres a second statement
+	// could COMMIT the read-only transaction and write outside it
+	for _, query := range []string{
+		"SELECT 1; SELECT 2",
+		"SELECT 1; DELETE FROM t",
+		"SELECT id FROM t; COMMIT; DROP TABLE t",
+	} {
+		if _, err := db.ExecuteQuery(ctx, query, 10); err == nil {
+			t.Errorf("ExecuteQuery(%q) succeeded in read-only mode", query)
+		}
+		if err := db.StreamQuery(ctx, query, func([]ResultColumn) error { return nil }, func([]interface{}) error { return nil }); err == nil {
+			t.Errorf("StreamQuery(%q) succeeded in read-only mode", query)
+		}
+	}
+
+	result, err := db.ExecuteQuery(ctx, "SELECT id FROM t;", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Count != 1 {
+		t.Errorf("got %d rows, want 1", result.Count)
+	}
+}
+
+func TestStreamQueryTimeoutCoversFirstRowOnly(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(ctx, "sqlite3", filepath.Join(t.TempDir(), "test.db"), Options{QueryTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A slow reader may take longer than the timeout once rows flow
+	rows := 0
+	err = db.StreamQuery(ctx, "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 5) SELECT x FROM c",
+		func([]ResultColumn) error { return nil },
+		func([]interface{}) error {
+			rows++
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("slow stream failed: %v", err)
+	}
+	if rows != 5 {
+		t.Errorf("got %d rows, want 5", rows)
+	}
+
+	// A query that produces no row in time is still stopped
+	err = db.StreamQuery(ctx, "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT max(x) FROM c",
+		func([]ResultColumn) error { return nil },
+		func([]interface{}) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+}
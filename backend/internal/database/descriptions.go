@@ -0,0 +1,44 @@
+package database
+
+import "strings"
+
+// DescriptionOverride is a locally stored description for a table (Column
+// empty) or one of its columns. It replaces the database comment, which
+// lets databases without comments, such as SQLite, be documented too.
+type DescriptionOverride struct {
+	Schema      string `json:"schema,omitempty"`
+	Table       string `json:"table"`
+	Column      string `json:"column,omitempty"`
+	Description string `json:"description"`
+}
+
+// SetDescriptionOverrides replaces the overrides applied by GetTableInfo.
+func (d *Database) SetDescriptionOverrides(overrides []DescriptionOverride) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.descriptions = make(map[string]string, len(overrides))
+	for _, o := range overrides {
+		d.descriptions[descriptionKey(TableName{Schema: o.Schema, Name: o.Table}, o.Column)] = o.Description
+	}
+}
+
+// applyDescriptions swaps in the overrides for info and its columns.
+func (d *Database) applyDescriptions(info *TableInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.descriptions) == 0 {
+		return
+	}
+	if desc, ok := d.descriptions[descriptionKey(info.TableName, "")]; ok {
+		info.Description = desc
+	}
+	for i := range info.Columns {
+		if desc, ok := d.descriptions[descriptionKey(info.TableName, info.Columns[i].Name)]; ok {
+			info.Columns[i].Description = desc
+		}
+	}
+}
+
+func descriptionKey(table TableName, column string) string {
+	return strings.ToLower(table.Schema + "\x00" + table.Name + "\x00" + column)
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ForeignKey is a foreign key constraint of a table. Columns[i] references
+// RefColumns[i]; composite keys list several columns.
+type ForeignKey struct {
+	Name       string    `json:"name,omitempty"` // empty for SQLite
+	Columns    []string  `json:"columns"`
+	RefTable   TableName `json:"ref_table"`
+	RefColumns []string  `json:"ref_columns"`
+}
+
+// getForeignKeys lists a table's foreign keys with their columns in key order.
+func (d *Database) getForeignKeys(ctx context.Context, table TableName) ([]ForeignKey, error) {
+	var query string
+	switch d.dbType {
+	case "postgres":
+		// pg_constraint, unlike information_schema, also shows keys of
+		// tables the current user does not own
+		query = `
+			SELECT
+				con.conname,
+				a.attname,
+				rn.nspname,
+				rc.relname,
+				ra.attname
+			FROM pg_constraint con
+			CROSS JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refnum, ord)
+			JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+			JOIN pg_class rc ON rc.oid = con.confrelid
+			JOIN pg_namespace rn ON rn.oid = rc.relnamespace
+			JOIN pg_attribute ra ON ra.attrelid = con.confrelid AND ra.attnum = k.refnum
+			WHERE con.conrelid = ` + pgRegclass + `
+			AND con.contype = 'f'
+			ORDER BY con.conname, k.ord
+		`
+	case "mysql":
+		query = `
+			SELECT
+				constraint_name,
+				column_name,
+				referenced_table_schema,
+				referenced_table_name,
+				referenced_column_name
+			FROM information_schema.key_column_usage
+			WHERE table_schema = ?
+			AND table_name = ?
+			AND referenced_table_name IS NOT NULL
+			ORDER BY constraint_name, ordinal_position
+		`
+	case "sqlite3":
+		return d.sqliteForeignKeys(ctx, table)
+	default:
+		return nil, nil
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, table.Schema, table.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var fks []ForeignKey
+	for rows.Next() {
+		var fk ForeignKey
+		var column, refColumn string
+		if err := rows.Scan(&fk.Name, &column, &fk.RefTable.Schema, &fk.RefTable.Name, &refColumn); err != nil {
+			return nil, err
+		}
+		// One row per key column
+		if n := len(fks); n > 0 && fks[n-1].Name == fk.Name {
+			fks[n-1].Columns = append(fks[n-1].Columns, column)
+			fks[n-1].RefColumns = append(fks[n-1].RefColumns, refColumn)
+			continue
+		}
+		fk.Columns = []string{column}
+		fk.RefColumns = []string{refColumn}
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+// sqliteForeignKeys reads a SQLite table's foreign keys. A key that names
+// no parent columns refers to the parent's primary key.
+func (d *Database) sqliteForeignKeys(ctx context.Context, table TableName) ([]ForeignKey, error) {
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%s)", d.quoteIdentifier(table.Name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var fks []ForeignKey
+	lastID := -1
+	for rows.Next() {
+		var id, seq int
+		var parent, from string
+		var to sql.NullString
+		var onUpdate, onDelete, match string
+		if err := rows.Scan(&id, &seq, &parent, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		if id != lastID {
+			fks = append(fks, ForeignKey{RefTable: TableName{Name: parent}})
+			lastID = id
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, from)
+		fk.RefColumns = append(fk.RefColumns, to.String)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range fks {
+		if fks[i].RefColumns[0] != "" {
+			continue
+		}
+		pk, err := d.sqlitePrimaryKey(ctx, fks[i].RefTable.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(pk) == len(fks[i].Columns) {
+			fks[i].RefColumns = pk
+		} else {
+			fks[i].RefColumns = nil // The parent has no matching primary key
+		}
+	}
+	return fks, nil
+}
+
+// sqlitePrimaryKey returns a SQLite table's primary key columns in key order.
+func (d *Database) sqlitePrimaryKey(ctx context.Context, table string) ([]string, error) {
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", d.quoteIdentifier(table)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	byPosition := make(map[int]string)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dfltValue interface{}
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return nil, err
+		}
+		if pk > 0 {
+			byPosition[pk] = name
+		}
+	}
+
+	pk := make([]string, len(byPosition))
+	for i := range pk {
+		pk[i] = byPosition[i+1]
+	}
+	return pk, rows.Err()
+}
+
+// markForeignKeys sets Column.ForeignKey from the table's foreign keys; a
+// column in several keys keeps the first.
+func (t *TableInfo) markForeignKeys() {
+	for _, fk := range t.ForeignKeys {
+		ref := fk.RefTable.Name
+		if fk.RefTable.Schema != t.Schema {
+			ref = fk.RefTable.String()
+		}
+		for i, name := range fk.Columns {
+			if i >= len(fk.RefColumns) {
+				break
+			}
+			for j := range t.Columns {
+				if t.Columns[j].Name == name && t.Columns[j].ForeignKey == "" {
+					t.Columns[j].ForeignKey = ref + "." + fk.RefColumns[i]
+				}
+			}
+		}
+	}
+}
+
+// relationship describes fk as a relationship from the table it belongs to.
+func (fk ForeignKey) relationship(from TableName) TableRelationship {
+	return TableRelationship{
+		Name:        fk.Name,
+		FromSchema:  from.Schema,
+		FromTable:   from.Name,
+		FromColumns: fk.Columns,
+		ToSchema:    fk.RefTable.Schema,
+		ToTable:     fk.RefTable.Name,
+		ToColumns:   fk.RefColumns,
+	}
+}
+
+// String renders the relationship for the LLM, e.g.
+// "orders.customer_id -> customers.id" or, for a composite key,
+// "order_items(sku, region) -> products(sku, region)".
+func (r TableRelationship) String() string {
+	from := TableName{Schema: r.FromSchema, Name: r.FromTable}
+	to := TableName{Schema: r.ToSchema, Name: r.ToTable}
+	s := keyColumns(from, r.FromColumns) + " -> " + keyColumns(to, r.ToColumns)
+	if r.Name != "" {
+		s += " (" + r.Name + ")"
+	}
+	return s
+}
+
+func keyColumns(table TableName, columns []string) string {
+	switch len(columns) {
+	case 0:
+		return table.String()
+	case 1:
+		return table.String() + "." + columns[0]
+	}
+	return table.String() + "(" + strings.Join(columns, ", ") + ")"
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Index struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"` // key columns or expressions, in order
+	Unique  bool     `json:"unique"`
+	Primary bool     `json:"primary,omitempty"`
+	Where   string   `json:"where,omitempty"` // predicate of a partial index
+}
+
+// Constraint types
+const (
+	ConstraintUnique = "unique"
+	ConstraintCheck  = "check"
+)
+
+type Constraint struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Columns    []string `json:"columns,omitempty"`
+	Definition string   `json:"definition"` // e.g. CHECK (amount > 0)
+}
+
+// pgRegclass resolves the $1 schema and $2 table parameters to an OID.
+const pgRegclass = "(quote_ident($1) || '.' || quote_ident($2))::regclass"
+
+// getIndexes lists a table's indexes.
+func (d *Database) getIndexes(ctx context.Context, table TableName) ([]Index, error) {
+	var query string
+	args := []interface{}{table.Schema, table.Name}
+	switch d.dbType {
+	case "postgres":
+		query = `
+			SELECT
+				i.relname,
+				ix.indisunique,
+				ix.indisprimary,
+				COALESCE(pg_get_expr(ix.indpred, ix.indrelid, true), ''),
+				pg_get_indexdef(ix.indexrelid, k.n, true)
+			FROM pg_index ix
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			CROSS JOIN LATERAL generate_series(1, ix.indnkeyatts) AS k(n)
+			WHERE ix.indrelid = ` + pgRegclass + `
+			ORDER BY ix.indisprimary DESC, i.relname, k.n
+		`
+	case "mysql":
+		query = `
+			SELECT
+				index_name,
+				non_unique = 0,
+				index_name = 'PRIMARY',
+				'',
+				COALESCE(column_name, '(expression)')
+			FROM information_schema.statistics
+			WHERE table_schema = ?
+			AND table_name = ?
+			ORDER BY index_name = 'PRIMARY' DESC, index_name, seq_in_index
+		`
+	case "sqlite3":
+		// The predicate of a partial index is only kept in its CREATE
+		// INDEX text. A rowid table's INTEGER PRIMARY KEY has no index.
+		query = `
+			SELECT
+				il.name,
+				il."unique",
+				il.origin = 'pk',
+				CASE WHEN il.partial THEN m.sql ELSE '' END,
+				COALESCE(ii.name, '(expression)')
+			FROM pragma_index_list(?) il
+			JOIN pragma_index_info(il.name) ii
+			LEFT JOIN sqlite_master m ON m.type = 'index' AND m.name = il.name
+			ORDER BY il.origin = 'pk' DESC, il.name, ii.seqno
+		`
+		args = args[1:]
+	default:
+		return nil, nil
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []Index
+	for rows.Next() {
+		var idx Index
+		var column string
+		if err := rows.Scan(&idx.Name, &idx.Unique, &idx.Primary, &idx.Where, &column); err != nil {
+			return nil, err
+		}
+		if d.dbType == "sqlite3" && idx.Where != "" {
+			idx.Where = partialPredicate(idx.Where)
+		}
+		// One row per key column
+		if n := len(indexes); n > 0 && indexes[n-1].Name == idx.Name {
+			indexes[n-1].Columns = append(indexes[n-1].Columns, column)
+			continue
+		}
+		idx.Columns = []string{column}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+var whereClause = regexp.MustCompile(`(?is)\bWHERE\b(.*)$`)
+
+// partialPredicate extracts the WHERE predicate from a CREATE INDEX statement.
+func partialPredicate(createIndex string) string {
+	if m := whereClause.FindStringSubmatch(createIndex); m != nil {
+		return oneLine(m[1])
+	}
+	return ""
+}
+
+// getConstraints lists a table's unique and check constraints. SQLite keeps
+// them only in the CREATE TABLE text, so none are returned for it.
+func (d *Database) getConstraints(ctx context.Context, table TableName) ([]Constraint, error) {
+	switch d.dbType {
+	case "postgres":
+		return d.queryConstraints(ctx, `
+			SELECT
+				con.conname,
+				CASE con.contype WHEN 'u' THEN 'unique' ELSE 'check' END,
+				COALESCE((
+					SELECT string_agg(a.attname, ',' ORDER BY k.ord)
+					FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+					JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+				), ''),
+				pg_get_constraintdef(con.oid, true)
+			FROM pg_constraint con
+			WHERE con.conrelid = `+pgRegclass+`
+			AND con.contype IN ('u', 'c')
+			ORDER BY con.conname
+		`, table)
+	case "mysql":
+		constraints, err := d.queryConstraints(ctx, `
+			SELECT
+				tc.constraint_name,
+				'unique',
+				GROUP_CONCAT(k.column_name ORDER BY k.ordinal_position),
+				''
+			FROM information_schema.table_constraints tc
+			JOIN information_schema.key_column_usage k
+				ON k.constraint_schema = tc.constraint_schema
+				AND k.constraint_name = tc.constraint_name
+				AND k.table_name = tc.table_name
+			WHERE tc.table_schema = ?
+			AND tc.table_name = ?
+			AND tc.constraint_type = 'UNIQUE'
+			GROUP BY tc.constraint_name
+			ORDER BY tc.constraint_name
+		`, table)
+		if err != nil {
+			return nil, err
+		}
+
+		// check_constraints needs MySQL 8.0.16 or MariaDB 10.2
+		checks, err := d.queryConstraints(ctx, `
+			SELECT cc.constraint_name, 'check', '', CONCAT('CHECK (', cc.check_clause, ')')
+			FROM information_schema.check_constraints cc
+			JOIN information_schema.table_constraints tc
+				ON tc.constraint_schema = cc.constraint_schema
+				AND tc.constraint_name = cc.constraint_name
+			WHERE tc.table_schema = ?
+			AND tc.table_name = ?
+			AND tc.constraint_type = 'CHECK'
+			ORDER BY cc.constraint_name
+		`, table)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return append(constraints, checks...), nil
+	default:
+		return nil, nil
+	}
+}
+
+func (d *Database) queryConstraints(ctx context.Context, query string, table TableName) ([]Constraint, error) {
+	rows, err := d.db.QueryContext(ctx, query, table.Schema, table.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query constraints: %w", err)
+	}
+	defer rows.Close()
+
+	var constraints []Constraint
+	for rows.Next() {
+		var con Constraint
+		var columns string
+		if err := rows.Scan(&con.Name, &con.Type, &columns, &con.Definition); err != nil {
+			return nil, err
+		}
+		if columns != "" {
+			con.Columns = strings.Split(columns, ",")
+		}
+		if con.Definition == "" && con.Type == ConstraintUnique {
+			con.Definition = "UNIQUE (" + strings.Join(con.Columns, ", ") + ")"
+		}
+		constraints = append(constraints, con)
+	}
+	return constraints, rows.Err()
+}
+
+// getViewDefinition returns the query behind a view or materialized view.
+func (d *Database) getViewDefinition(ctx context.Context, table TableName) (string, error) {
+	var query string
+	args := []interface{}{table.Schema, table.Name}
+	switch d.dbType {
+	case "postgres":
+		query = "SELECT pg_get_viewdef(" + pgRegclass + ", true)"
+	case "mysql":
+		query = `
+			SELECT view_definition
+			FROM information_schema.views
+			WHERE table_schema = ?
+			AND table_name = ?
+		`
+	case "sqlite3":
+		query = "SELECT sql FROM sqlite_master WHERE type = 'view' AND name = ?"
+		args = args[1:]
+	default:
+		return "", nil
+	}
+
+	var definition sql.NullString
+	if err := d.db.QueryRowContext(ctx, query, args...).Scan(&definition); err != nil {
+		return "", fmt.Errorf("failed to read view definition: %w", err)
+	}
+	return strings.TrimSpace(definition.String), nil
+}
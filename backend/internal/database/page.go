@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PageRequest selects one page of a query's result. With Keys set, rows are
+// ordered by those columns; After then holds the key values of the last row
+// already seen (as they appeared in the JSON result) and the page starts
+// right after it (keyset paging). Offset skips rows in either mode.
+type PageRequest struct {
+	Offset     int
+	Limit      int
+	Keys       []ResultColumn
+	Descending bool
+	After      []interface{}
+}
+
+// FetchPage runs query wrapped in a derived table and returns the requested
+// page, reporting whether more rows follow it. The statement must be a
+// plain read-only query (SELECT, VALUES or WITH ... SELECT). Queries that
+// cannot be wrapped, because of repeated column names, run as written and
+// are paged by offset only.
+func (d *Database) FetchPage(ctx context.Context, query string, page PageRequest) (*QueryResult, bool, error) {
+	if page.Limit <= 0 {
+		return nil, false, errors.New("page limit must be positive")
+	}
+	if !d.plainSelect(query) {
+		return nil, false, errors.New("only SELECT queries can be paged")
+	}
+	if len(page.After) > 0 && len(page.After) != len(page.Keys) {
+		return nil, false, errors.New("cursor does not match the key columns")
+	}
+	if !d.wrappable(query) {
+		if len(page.Keys) > 0 {
+			return nil, false, errors.New("keyset paging needs distinct column names; page by offset instead")
+		}
+		return d.fetchPageAsWritten(ctx, query, page)
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+	sb.WriteString("SELECT * FROM (\n" + trimStatement(query) + "\n) AS _page")
+
+	if len(page.Keys) > 0 {
+		keys := make([]string, len(page.Keys))
+		order := make([]string, len(page.Keys))
+		for i, key := range page.Keys {
+			keys[i] = d.quoteIdentifier(key.Name)
+			order[i] = keys[i]
+			if page.Descending {
+				order[i] += " DESC"
+			}
+		}
+
+		if len(page.After) > 0 {
+			params := make([]string, len(page.After))
+			for i, v := range page.After {
+				arg, err := d.keyArg(page.Keys[i], v)
+				if err != nil {
+					return nil, false, err
+				}
+				args = append(args, arg)
+				params[i] = d.placeholder(len(args))
+			}
+			op := ">"
+			if page.Descending {
+				op = "<"
+			}
+			if len(keys) == 1 {
+				fmt.Fprintf(&sb, " WHERE %s %s %s", keys[0], op, params[0])
+			} else {
+				fmt.Fprintf(&sb, " WHERE (%s) %s (%s)", strings.Join(keys, ", "), op, strings.Join(params, ", "))
+			}
+		}
+		sb.WriteString(" ORDER BY " + strings.Join(order, ", "))
+	}
+
+	// One extra row tells whether another page follows
+	fmt.Fprintf(&sb, " LIMIT %d", page.Limit+1)
+	if page.Offset > 0 {
+		fmt.Fprintf(&sb, " OFFSET %d", page.Offset)
+	}
+
+	var result *QueryResult
+	err := d.runQuery(ctx, sb.String(), args, func(rows *sql.Rows) error {
+		var err error
+		result, err = readResult(rows, page.Limit+1)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch page: %w", err)
+	}
+
+	return trimPage(result, page.Limit)
+}
+
+// fetchPageAsWritten pages query by skipping rows as they are read, for
+// statements that cannot be wrapped.
+func (d *Database) fetchPageAsWritten(ctx context.Context, query string, page PageRequest) (*QueryResult, bool, error) {
+	var result *QueryResult
+	err := d.runQuery(ctx, trimStatement(query), nil, func(rows *sql.Rows) error {
+		for skipped := 0; skipped < page.Offset; skipped++ {
+			if !rows.Next() {
+				break
+			}
+		}
+		var err error
+		result, err = readResult(rows, page.Limit+1)
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	return trimPage(result, page.Limit)
+}
+
+// trimPage drops the extra row fetched to tell whether another page
+// follows, and reports whether there was one.
+func trimPage(result *QueryResult, limit int) (*QueryResult, bool, error) {
+	hasMore := len(result.Rows) > limit
+	if hasMore {
+		result.Rows = result.Rows[:limit]
+		result.Count = limit
+	}
+	return result, hasMore, nil
+}
+
+// CountRows returns the number of rows query produces, without fetching
+// them. Queries that cannot be wrapped, because of repeated column names,
+// are counted by reading their rows.
+func (d *Database) CountRows(ctx context.Context, query string) (int64, error) {
+	if !d.plainSelect(query) {
+		return 0, errors.New("only SELECT queries can be counted")
+	}
+	var total int64
+	if !d.wrappable(query) {
+		err := d.runQuery(ctx, trimStatement(query), nil, func(rows *sql.Rows) error {
+			for rows.Next() {
+				total++
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to count rows: %w", err)
+		}
+		return total, nil
+	}
+
+	countQuery := "SELECT COUNT(*) FROM (\n" + trimStatement(query) + "\n) AS _count"
+	err := d.runQuery(ctx, countQuery, nil, func(rows *sql.Rows) error {
+		if !rows.Next() {
+			return errors.New("count returned no rows")
+		}
+		return rows.Scan(&total)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return total, nil
+}
+
+// trimStatement strips whitespace and trailing semicolons so the statement
+// can be embedded in another one.
+func trimStatement(query string) string {
+	return strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+}
+
+func (d *Database) placeholder(n int) string {
+	if d.dbType == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func (d *Database) quoteIdentifier(name string) string {
+	if d.dbType == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// keyArg turns a key value from a JSON result back into a query argument
+// that compares correctly against the column.
+func (d *Database) keyArg(col ResultColumn, v interface{}) (interface{}, error) {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			v = i
+		} else if f, err := n.Float64(); err == nil {
+			v = f
+		} else {
+			v = n.String()
+		}
+	}
+
+	s, isString := v.(string)
+	switch {
+	case v == nil:
+		return nil, fmt.Errorf("cannot page after a NULL value in key column %q", col.Name)
+	case col.Kind == KindBinary && isString:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor value for %q: %w", col.Name, err)
+		}
+		return b, nil
+	case (col.Kind == KindTimestamp || col.Kind == KindDate) && isString:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor value for %q: %w", col.Name, err)
+		}
+		if d.dbType == "sqlite3" {
+			// SQLite compares dates as text, usually stored in this form
+			if col.Kind == KindDate {
+				return t.Format(time.DateOnly), nil
+			}
+			return t.Format("2006-01-02 15:04:05.999999999"), nil
+		}
+		return t, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// profileSampleRows caps the rows read from each table
+	profileSampleRows = 10000
+	// profileTopValues is how many common values are kept per column
+	profileTopValues = 5
+	// enumMaxValues is the most distinct values an enum-like column has
+	enumMaxValues = 12
+	// profileTimeout bounds profiling one table; columns left over are skipped
+	profileTimeout = 5 * time.Second
+	// profileCacheTTL is how long a table profile is reused
+	profileCacheTTL = 10 * time.Minute
+	// maxProfileValueLength shortens long sample values in summaries
+	maxProfileValueLength = 40
+)
+
+// ColumnProfile summarizes the values of one column over a sample of rows.
+type ColumnProfile struct {
+	Name          string       `json:"name"`
+	DistinctCount int64        `json:"distinct_count"`
+	NullRatio     float64      `json:"null_ratio"`
+	Min           string       `json:"min,omitempty"` // numeric and temporal columns
+	Max           string       `json:"max,omitempty"`
+	TopValues     []ValueCount `json:"top_values,omitempty"` // most common values first
+	// Enum marks a low-cardinality column; TopValues then holds every
+	// value seen
+	Enum bool `json:"enum,omitempty"`
+
+	// numeric values are written without quotes
+	numeric bool
+}
+
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// TableProfile holds the column profiles of a table or materialized view.
+type TableProfile struct {
+	TableName
+	SampleRows int64           `json:"sample_rows"` // rows the profile was computed over
+	Sampled    bool            `json:"sampled"`     // the table has more rows than were read
+	Partial    bool            `json:"partial"`     // time ran out before every column was profiled
+	Columns    []ColumnProfile `json:"columns"`
+	ProfiledAt time.Time       `json:"profiled_at"`
+}
+
+// valueKind groups column types by what is worth collecting for them.
+type valueKind int
+
+const (
+	kindOther   valueKind = iota // distinct count and common values
+	kindText                     // also example values when not enum-like
+	kindOrdered                  // also min and max
+	kindSkipped                  // not profiled: binary, JSON, arrays, ...
+)
+
+func columnKind(columnType string) valueKind {
+	t := strings.ToLower(columnType)
+	for _, s := range []string{"json", "xml", "bytea", "blob", "binary", "array", "[]", "geometry", "geography", "point", "polygon", "tsvector", "bit"} {
+		if strings.Contains(t, s) {
+			return kindSkipped
+		}
+	}
+	for _, s := range []string{"int", "numeric", "decimal", "real", "double", "float", "money", "serial", "date", "time", "year"} {
+		if strings.Contains(t, s) {
+			return kindOrdered
+		}
+	}
+	for _, s := range []string{"char", "text", "clob", "enum", "set", "user-defined", "citext"} {
+		if strings.Contains(t, s) {
+			return kindText
+		}
+	}
+	return kindOther
+}
+
+// ProfileTable samples the values of a table's columns: distinct counts,
+// NULL ratio, ranges and the most common values. Profiles are cached for a
+// while, and each table gets a short time budget; columns that do not fit
+// in it, or whose statistics fail, are left out. Views are not profiled.
+func (d *Database) ProfileTable(ctx context.Context, table TableInfo) (*TableProfile, error) {
+	if table.Type == TableTypeView {
+		return nil, fmt.Errorf("%s is a view", table.TableName)
+	}
+
+	d.mu.Lock()
+	cached, ok := d.profiles[table.TableName]
+	d.mu.Unlock()
+	if ok && time.Since(cached.ProfiledAt) < profileCacheTTL {
+		return cached, nil
+	}
+
+	profileCtx, cancel := context.WithTimeout(ctx, profileTimeout)
+	defer cancel()
+
+	profile := &TableProfile{TableName: table.TableName, ProfiledAt: time.Now()}
+	for _, col := range table.Columns {
+		if columnKind(col.Type) == kindSkipped {
+			continue
+		}
+
+		cp, rows, err := d.profileColumn(profileCtx, table.TableName, col)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if profileCtx.Err() != nil {
+				profile.Partial = true
+				break
+			}
+			continue // e.g. a type without equality or ordering
+		}
+		profile.SampleRows = rows
+		profile.Columns = append(profile.Columns, *cp)
+	}
+	profile.Sampled = profile.SampleRows >= profileSampleRows
+
+	d.mu.Lock()
+	if d.profiles == nil {
+		d.profiles = make(map[TableName]*TableProfile)
+	}
+	d.profiles[table.TableName] = profile
+	d.mu.Unlock()
+
+	return profile, nil
+}
+
+// ClearProfiles drops cached table profiles, e.g. after the schema changed.
+func (d *Database) ClearProfiles() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.profiles = nil
+}
+
+// profileColumn computes one column's profile, returning it with the
+// number of sampled rows.
+func (d *Database) profileColumn(ctx context.Context, table TableName, column Column) (*ColumnProfile, int64, error) {
+	sample := fmt.Sprintf("(SELECT %s AS v FROM %s LIMIT %d) AS s",
+		d.quoteIdentifier(column.Name), d.quoteTable(table), profileSampleRows)
+	kind := columnKind(column.Type)
+	temporal := strings.Contains(strings.ToLower(column.Type), "date") || strings.Contains(strings.ToLower(column.Type), "time")
+
+	bounds := "NULL, NULL"
+	if kind == kindOrdered {
+		bounds = "MIN(v), MAX(v)"
+	}
+
+	var rows, nonNull int64
+	var minValue, maxValue sql.NullString
+	cp := &ColumnProfile{Name: column.Name, numeric: kind == kindOrdered && !temporal}
+	err := d.db.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(v), COUNT(DISTINCT v), "+bounds+" FROM "+sample).
+		Scan(&rows, &nonNull, &cp.DistinctCount, &minValue, &maxValue)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to profile %s: %w", column.Name, err)
+	}
+	if rows > 0 {
+		cp.NullRatio = float64(rows-nonNull) / float64(rows)
+	}
+	cp.Min, cp.Max = minValue.String, maxValue.String
+
+	// Values repeat in an enum-like column; otherwise only text columns
+	// get examples, to show how values are written
+	cp.Enum = cp.DistinctCount > 0 && cp.DistinctCount <= enumMaxValues && nonNull >= 2*cp.DistinctCount
+	limit := 0
+	switch {
+	case cp.Enum:
+		limit = enumMaxValues
+	case kind == kindText && cp.DistinctCount > 0:
+		limit = profileTopValues
+	}
+	if limit == 0 {
+		return cp, rows, nil
+	}
+
+	valueRows, err := d.db.QueryContext(ctx, fmt.Sprintf(
+		"SELECT v, COUNT(*) FROM %s WHERE v IS NOT NULL GROUP BY v ORDER BY COUNT(*) DESC, v LIMIT %d", sample, limit))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to sample values of %s: %w", column.Name, err)
+	}
+	defer valueRows.Close()
+
+	for valueRows.Next() {
+		var vc ValueCount
+		var value sql.NullString
+		if err := valueRows.Scan(&value, &vc.Count); err != nil {
+			return nil, 0, err
+		}
+		vc.Value = value.String
+		cp.TopValues = append(cp.TopValues, vc)
+	}
+	if err := valueRows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return cp, rows, nil
+}
+
+// Summary lists what the profile says about each column's values, for the
+// LLM: allowed values of enum-like columns, ranges, and example values.
+func (p TableProfile) Summary() string {
+	var sb strings.Builder
+	switch {
+	case len(p.Columns) == 0:
+		return ""
+	case p.SampleRows == 0:
+		return fmt.Sprintf("Values in %s: the table is empty\n", p.TableName)
+	case p.Sampled:
+		sb.WriteString(fmt.Sprintf("Values in %s (sampled from %d rows):\n", p.TableName, p.SampleRows))
+	default:
+		sb.WriteString(fmt.Sprintf("Values in %s:\n", p.TableName))
+	}
+
+	for _, col := range p.Columns {
+		var facts []string
+		switch {
+		case col.Enum:
+			facts = append(facts, "one of "+col.formatValues())
+		case col.Min != "" && col.Max != "":
+			facts = append(facts, fmt.Sprintf("%s to %s", shorten(col.Min), shorten(col.Max)))
+		}
+		if !col.Enum {
+			facts = append(facts, fmt.Sprintf("%d distinct", col.DistinctCount))
+			if len(col.TopValues) > 0 {
+				facts = append(facts, "e.g. "+col.formatValues())
+			}
+		}
+		switch {
+		case col.NullRatio >= 1:
+			facts = []string{"always NULL"}
+		case col.NullRatio >= 0.01:
+			facts = append(facts, fmt.Sprintf("%.0f%% NULL", col.NullRatio*100))
+		case col.NullRatio > 0:
+			facts = append(facts, "<1% NULL")
+		}
+		sb.WriteString(fmt.Sprintf("  - %s: %s\n", col.Name, strings.Join(facts, ", ")))
+	}
+	return sb.String()
+}
+
+// formatValues writes the top values as SQL literals.
+func (c ColumnProfile) formatValues() string {
+	values := make([]string, len(c.TopValues))
+	for i, vc := range c.TopValues {
+		if c.numeric {
+			values[i] = shorten(vc.Value)
+		} else {
+			values[i] = "'" + strings.ReplaceAll(shorten(vc.Value), "'", "''") + "'"
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+func shorten(s string) string {
+	s = oneLine(s)
+	if r := []rune(s); len(r) > maxProfileValueLength {
+		return string(r[:maxProfileValueLength]) + "..."
+	}
+	return s
+}
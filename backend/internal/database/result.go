@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column kinds reported in ResultColumn.Kind. They describe how values are
+// encoded in JSON, independently of the driver.
+const (
+	KindString    = "string"    // JSON string
+	KindInteger   = "integer"   // JSON number
+	KindFloat     = "float"     // JSON number, null for NaN/Inf
+	KindDecimal   = "decimal"   // JSON string holding the exact value
+	KindBoolean   = "boolean"   // JSON boolean
+	KindTimestamp = "timestamp" // RFC 3339 string
+	KindDate      = "date"      // YYYY-MM-DD string
+	KindTime      = "time"      // HH:MM:SS[.fraction] string
+	KindUUID      = "uuid"      // canonical 8-4-4-4-12 string
+	KindJSON      = "json"      // embedded JSON value
+	KindBinary    = "binary"    // base64 string
+)
+
+// ResultColumn describes one column of a query result, taken from the
+// driver's column type information.
+type ResultColumn struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"database_type"`
+	Kind         string `json:"kind"`
+	GoType       string `json:"go_type,omitempty"`
+	Nullable     *bool  `json:"nullable,omitempty"`
+	Length       int64  `json:"length,omitempty"`
+	Precision    int64  `json:"precision,omitempty"`
+	Scale        int64  `json:"scale,omitempty"`
+}
+
+// Decimal is an exact numeric value kept in its textual form so no
+// precision is lost to float64.
+type Decimal string
+
+// ResultColumns describes the columns of a result set.
+func ResultColumns(types []*sql.ColumnType) []ResultColumn {
+	columns := make([]ResultColumn, len(types))
+	for i, ct := range types {
+		col := ResultColumn{
+			Name:         ct.Name(),
+			DatabaseType: ct.DatabaseTypeName(),
+		}
+		col.Kind = kindOf(col.DatabaseType)
+		if scanType := ct.ScanType(); scanType != nil {
+			// SQLite expressions have no declared type and scan into interface{}
+			if name := scanType.String(); name != "*interface {}" && name != "interface {}" {
+				col.GoType = name
+			}
+		}
+		if nullable, ok := ct.Nullable(); ok {
+			col.Nullable = &nullable
+		}
+		if length, ok := ct.Length(); ok && length > 0 && length < math.MaxInt32 {
+			col.Length = length
+		}
+		if precision, scale, ok := ct.DecimalSize(); ok {
+			col.Precision, col.Scale = precision, scale
+		}
+		columns[i] = col
+	}
+	return columns
+}
+
+// kindOf maps PostgreSQL, MySQL and SQLite type names to a kind. SQLite
+// reports the declared type, which may carry a size like VARCHAR(255), and
+// an empty name for expressions.
+func kindOf(typeName string) string {
+	name := strings.ToUpper(strings.TrimSpace(typeName))
+	if i := strings.IndexByte(name, '('); i >= 0 {
+		name = strings.TrimSpace(name[:i])
+	}
+
+	switch name {
+	case "BOOL", "BOOLEAN":
+		return KindBoolean
+	case "INT", "INT2", "INT4", "INT8", "INTEGER", "SMALLINT", "BIGINT", "TINYINT", "MEDIUMINT",
+		"UNSIGNED INT", "UNSIGNED SMALLINT", "UNSIGNED TINYINT", "UNSIGNED MEDIUMINT", "YEAR":
+		return KindInteger
+	case "UNSIGNED BIGINT", "NUMERIC", "DECIMAL", "MONEY":
+		// Unsigned BIGINT may not fit in an int64
+		return KindDecimal
+	case "FLOAT", "FLOAT4", "FLOAT8", "DOUBLE", "DOUBLE PRECISION", "REAL":
+		return KindFloat
+	case "DATE":
+		return KindDate
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATETIME":
+		return KindTimestamp
+	case "TIME", "TIMETZ":
+		return KindTime
+	case "UUID":
+		return KindUUID
+	case "JSON", "JSONB":
+		return KindJSON
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY", "BIT":
+		return KindBinary
+	default:
+		return KindString
+	}
+}
+
+// Normalize converts a scanned driver value to one of nil, int64, float64,
+// bool, string, Decimal, time.Time or []byte (binary columns only), so that
+// all three drivers yield the same Go types for the same kind of column.
+func (c ResultColumn) Normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return c.normalizeText(string(val), val)
+	case string:
+		return c.normalizeText(val, nil)
+	case int64:
+		switch c.Kind {
+		case KindBoolean:
+			return val != 0
+		case KindDecimal:
+			return Decimal(strconv.FormatInt(val, 10))
+		}
+		return val
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case uint64:
+		if val > math.MaxInt64 {
+			return Decimal(strconv.FormatUint(val, 10))
+		}
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint8:
+		return int64(val)
+	case float32:
+		return float64(val)
+	case float64:
+		if c.Kind == KindDecimal {
+			// SQLite stores decimals as REAL
+			return Decimal(strconv.FormatFloat(val, 'f', -1, 64))
+		}
+		return val
+	case bool, time.Time:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// timeLayouts are the textual forms of dates and times accepted from
+// drivers that return them as strings (SQLite, MySQL without parseTime).
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+// normalizeText interprets a textual driver value according to the column
+// kind. MySQL returns most types, and PostgreSQL numerics and UUIDs, as
+// bytes.
+func (c ResultColumn) normalizeText(s string, raw []byte) interface{} {
+	switch c.Kind {
+	case KindBinary:
+		if raw == nil {
+			raw = []byte(s)
+		}
+		return raw
+	case KindDecimal:
+		return Decimal(s)
+	case KindInteger:
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case KindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case KindBoolean:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case KindTimestamp, KindDate:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	case KindUUID:
+		if len(raw) == 16 {
+			h := hex.EncodeToString(raw)
+			return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+		}
+		return strings.ToLower(s)
+	}
+	return s
+}
+
+// JSONValue is the value as it appears in API responses; see the Kind
+// constants for the encoding of each kind.
+func (c ResultColumn) JSONValue(v interface{}) interface{} {
+	switch val := c.Normalize(v).(type) {
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return nil
+		}
+		return val
+	case Decimal:
+		return string(val)
+	case time.Time:
+		return c.FormatTime(val)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val)
+	case string:
+		if c.Kind == KindJSON && json.Valid([]byte(val)) {
+			return json.RawMessage(val)
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// FormatTime renders t according to the column kind.
+func (c ResultColumn) FormatTime(t time.Time) string {
+	switch c.Kind {
+	case KindDate:
+		return t.Format(time.DateOnly)
+	case KindTime:
+		return t.Format("15:04:05.999999999")
+	default:
+		return t.Format(time.RFC3339Nano)
+	}
+}
@@ -0,0 +1,590 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var (
+	ErrTableNotFound  = errors.New("table not found")
+	ErrAmbiguousTable = errors.New("ambiguous table name")
+)
+
+// TableName identifies a table. Schema is the Postgres schema or MySQL
+// database the table lives in; it is empty for SQLite.
+type TableName struct {
+	Schema string `json:"schema,omitempty"`
+	Name   string `json:"name"`
+}
+
+// String returns the schema-qualified name, as it should be written in SQL.
+func (t TableName) String() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// ParseTableName splits "schema.table" into its parts. A name without a
+// dot has no schema.
+func ParseTableName(s string) TableName {
+	if i := strings.Index(s, "."); i >= 0 {
+		return TableName{Schema: s[:i], Name: s[i+1:]}
+	}
+	return TableName{Name: s}
+}
+
+// Table types
+const (
+	TableTypeTable            = "table"
+	TableTypeView             = "view"
+	TableTypeMaterializedView = "materialized_view"
+)
+
+type TableInfo struct {
+	TableName
+	Type        string       `json:"type"`
+	Definition  string       `json:"definition,omitempty"` // query behind a view
+	Columns     []Column     `json:"columns"`
+	ForeignKeys []ForeignKey `json:"foreign_keys,omitempty"`
+	Indexes     []Index      `json:"indexes,omitempty"`
+	Constraints []Constraint `json:"constraints,omitempty"`
+	RowCount    int64        `json:"row_count"`
+	// Description is the table comment, or a locally stored override
+	Description string `json:"description,omitempty"`
+}
+
+type Column struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Nullable   bool   `json:"nullable"`
+	PrimaryKey bool   `json:"primary_key"`
+	// ForeignKey is the "table.column" this column references, from the
+	// first foreign key it is part of; see TableInfo.ForeignKeys
+	ForeignKey string `json:"foreign_key,omitempty"`
+	Default    string `json:"default,omitempty"` // default expression
+	// Description is the column comment, or a locally stored override
+	Description string `json:"description,omitempty"`
+}
+
+type SchemaInfo struct {
+	Tables        []TableInfo         `json:"tables"`
+	Relationships []TableRelationship `json:"relationships"`
+	Summary       string              `json:"summary"`
+}
+
+// TableRelationship is a foreign key between two tables. FromColumns[i]
+// references ToColumns[i]; composite keys list several columns.
+type TableRelationship struct {
+	Name        string   `json:"name,omitempty"` // constraint name, empty for SQLite
+	FromSchema  string   `json:"from_schema,omitempty"`
+	FromTable   string   `json:"from_table"`
+	FromColumns []string `json:"from_columns"`
+	ToSchema    string   `json:"to_schema,omitempty"`
+	ToTable     string   `json:"to_table"`
+	ToColumns   []string `json:"to_columns"`
+}
+
+// systemSchemas are never introspected.
+var systemSchemas = map[string]map[string]bool{
+	"postgres": {"pg_catalog": true, "information_schema": true, "pg_toast": true},
+	"mysql":    {"mysql": true, "information_schema": true, "performance_schema": true, "sys": true},
+}
+
+// includeSchema applies the Schemas and ExcludeSchemas options, which hold
+// exact names or path.Match patterns such as "stg_*".
+func (d *Database) includeSchema(schema string) bool {
+	if systemSchemas[d.dbType][schema] || strings.HasPrefix(schema, "pg_temp_") || strings.HasPrefix(schema, "pg_toast_temp_") {
+		return false
+	}
+	if len(d.schemas) > 0 && !matchSchema(d.schemas, schema) {
+		return false
+	}
+	return !matchSchema(d.excludeSchemas, schema)
+}
+
+func matchSchema(patterns []string, schema string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, schema); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTables lists the tables, views and materialized views in the included
+// schemas; only TableName, Type and Description are set. Without a Schemas option,
+// Postgres lists every non-system schema and MySQL only the connection's
+// database.
+func (d *Database) GetTables(ctx context.Context) ([]TableInfo, error) {
+	var query string
+	switch d.dbType {
+	case "postgres":
+		query = `
+			SELECT table_schema, table_name,
+				CASE WHEN table_type = 'VIEW' THEN 'view' ELSE 'table' END,
+				COALESCE(obj_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass, 'pg_class'), '')
+			FROM information_schema.tables
+			WHERE table_type IN ('BASE TABLE', 'VIEW')
+			AND table_schema NOT IN ('pg_catalog', 'information_schema')
+			UNION ALL
+			SELECT schemaname, matviewname, 'materialized_view',
+				COALESCE(obj_description((quote_ident(schemaname) || '.' || quote_ident(matviewname))::regclass, 'pg_class'), '')
+			FROM pg_matviews
+			ORDER BY 1, 2
+		`
+	case "mysql":
+		filter := "table_schema = DATABASE()"
+		if len(d.schemas) > 0 {
+			filter = "table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')"
+		}
+		query = `
+			SELECT table_schema, table_name,
+				CASE WHEN table_type = 'VIEW' THEN 'view' ELSE 'table' END,
+				CASE WHEN table_type = 'VIEW' THEN '' ELSE COALESCE(table_comment, '') END
+			FROM information_schema.tables
+			WHERE ` + filter + `
+			AND table_type IN ('BASE TABLE', 'VIEW')
+			ORDER BY table_schema, table_name
+		`
+	case "sqlite3":
+		query = `
+			SELECT '', name, type, ''
+			FROM sqlite_master
+			WHERE type IN ('table', 'view')
+			AND name NOT LIKE 'sqlite_%'
+			ORDER BY name
+		`
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", d.dbType)
+	}
+
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []TableInfo
+	for rows.Next() {
+		var table TableInfo
+		if err := rows.Scan(&table.Schema, &table.Name, &table.Type, &table.Description); err != nil {
+			return nil, err
+		}
+		if d.dbType != "sqlite3" && !d.includeSchema(table.Schema) {
+			continue
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// FindTable resolves a table name as given by a user or the API. An
+// unqualified name matches a table in any included schema as long as it is
+// unique.
+func (d *Database) FindTable(ctx context.Context, name string) (TableInfo, error) {
+	tables, err := d.GetTables(ctx)
+	if err != nil {
+		return TableInfo{}, err
+	}
+
+	wanted := ParseTableName(name)
+	var matches []TableInfo
+	for _, table := range tables {
+		if table.TableName == wanted || (d.dbType == "sqlite3" || wanted.Schema == "") && table.Name == name {
+			matches = append(matches, table)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return TableInfo{}, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	case 1:
+		return matches[0], nil
+	default:
+		return TableInfo{}, fmt.Errorf("%w: %s exists in several schemas; qualify it, e.g. %s", ErrAmbiguousTable, name, matches[0].TableName)
+	}
+}
+
+// GetTableInfo fills in the details of a table or view returned by
+// GetTables or FindTable, with any description overrides applied. Foreign
+// keys, indexes, constraints and view definitions are best effort: the
+// table is still returned if they cannot be read.
+func (d *Database) GetTableInfo(ctx context.Context, table TableInfo) (*TableInfo, error) {
+	info := &TableInfo{TableName: table.TableName, Type: table.Type, Description: table.Description}
+
+	var err error
+	info.Columns, err = d.getColumns(ctx, table.TableName, table.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.Columns) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, table.TableName)
+	}
+
+	if table.Type == TableTypeTable || table.Type == TableTypeMaterializedView {
+		if info.Indexes, err = d.getIndexes(ctx, table.TableName); err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if table.Type == TableTypeTable {
+		if info.ForeignKeys, err = d.getForeignKeys(ctx, table.TableName); err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		info.markForeignKeys()
+		if info.Constraints, err = d.getConstraints(ctx, table.TableName); err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	} else {
+		if info.Definition, err = d.getViewDefinition(ctx, table.TableName); err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	// Counting a view would run its whole query
+	if table.Type != TableTypeView {
+		info.RowCount, err = d.getRowCount(ctx, table.TableName)
+		if err != nil {
+			info.RowCount = 0 // Non-critical error
+		}
+	}
+
+	d.applyDescriptions(info)
+	return info, nil
+}
+
+func (d *Database) getColumns(ctx context.Context, table TableName, tableType string) ([]Column, error) {
+	var query string
+	args := []interface{}{table.Schema, table.Name}
+	switch {
+	case d.dbType == "postgres" && tableType == TableTypeMaterializedView:
+		// information_schema does not cover materialized views
+		query = `
+			SELECT
+				a.attname,
+				format_type(a.atttypid, a.atttypmod),
+				CASE WHEN a.attnotnull THEN 'NO' ELSE 'YES' END,
+				false,
+				NULL,
+				COALESCE(col_description(a.attrelid, a.attnum), '')
+			FROM pg_attribute a
+			WHERE a.attrelid = (quote_ident($1) || '.' || quote_ident($2))::regclass
+			AND a.attnum > 0
+			AND NOT a.attisdropped
+			ORDER BY a.attnum
+		`
+	case d.dbType == "postgres":
+		query = `
+			SELECT
+				c.column_name,
+				c.data_type,
+				c.is_nullable,
+				EXISTS (
+					SELECT 1
+					FROM information_schema.table_constraints tc
+					JOIN information_schema.key_column_usage ku
+						ON tc.constraint_schema = ku.constraint_schema
+						AND tc.constraint_name = ku.constraint_name
+					WHERE tc.table_schema = c.table_schema
+					AND tc.table_name = c.table_name
+					AND tc.constraint_type = 'PRIMARY KEY'
+					AND ku.column_name = c.column_name
+				) as is_primary,
+				c.column_default,
+				COALESCE(col_description(
+					(quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass,
+					(SELECT a.attnum FROM pg_attribute a
+						WHERE a.attrelid = (quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass
+						AND a.attname = c.column_name)
+				), '')
+			FROM information_schema.columns c
+			WHERE c.table_schema = $1
+			AND c.table_name = $2
+			ORDER BY c.ordinal_position
+		`
+	case d.dbType == "mysql":
+		query = `
+			SELECT
+				c.column_name,
+				c.data_type,
+				c.is_nullable,
+				CASE WHEN c.column_key = 'PRI' THEN true ELSE false END as is_primary,
+				c.column_default,
+				COALESCE(c.column_comment, '')
+			FROM information_schema.columns c
+			WHERE c.table_schema = ?
+			AND c.table_name = ?
+			ORDER BY c.ordinal_position
+		`
+	case d.dbType == "sqlite3":
+		query = fmt.Sprintf("PRAGMA table_info(%s)", d.quoteIdentifier(table.Name))
+		args = nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", d.dbType)
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []Column
+	if d.dbType == "sqlite3" {
+		for rows.Next() {
+			var cid int
+			var name, colType string
+			var notNull, pk int
+			var dfltValue interface{}
+
+			if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+				return nil, err
+			}
+
+			// pk is the column's position in the primary key
+			col := Column{
+				Name:       name,
+				Type:       colType,
+				Nullable:   notNull == 0,
+				PrimaryKey: pk > 0,
+			}
+			if dfltValue != nil {
+				col.Default = fmt.Sprintf("%s", dfltValue)
+			}
+			columns = append(columns, col)
+		}
+	} else {
+		for rows.Next() {
+			var col Column
+			var nullable string
+			var dflt sql.NullString
+
+			if err := rows.Scan(&col.Name, &col.Type, &nullable, &col.PrimaryKey, &dflt, &col.Description); err != nil {
+				return nil, err
+			}
+
+			col.Nullable = nullable == "YES"
+			col.Default = dflt.String
+			columns = append(columns, col)
+		}
+	}
+
+	return columns, rows.Err()
+}
+
+func (d *Database) getRowCount(ctx context.Context, table TableName) (int64, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", d.quoteTable(table))
+	var count int64
+	err := d.db.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+func (d *Database) quoteTable(table TableName) string {
+	if table.Schema == "" {
+		return d.quoteIdentifier(table.Name)
+	}
+	return d.quoteIdentifier(table.Schema) + "." + d.quoteIdentifier(table.Name)
+}
+
+func (d *Database) GetFullSchema(ctx context.Context) (*SchemaInfo, error) {
+	tables, err := d.GetTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tableInfos []TableInfo
+	var relationships []TableRelationship
+
+	for _, table := range tables {
+		info, err := d.GetTableInfo(ctx, table)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // Skip tables we can't read
+		}
+		tableInfos = append(tableInfos, *info)
+
+		for _, fk := range info.ForeignKeys {
+			relationships = append(relationships, fk.relationship(table.TableName))
+		}
+	}
+
+	summary := generateSchemaSummary(tableInfos, relationships)
+
+	return &SchemaInfo{
+		Tables:        tableInfos,
+		Relationships: relationships,
+		Summary:       summary,
+	}, nil
+}
+
+// Subset returns the schema restricted to the given tables, keeping only
+// the relationships between them, with its own summary.
+func (s *SchemaInfo) Subset(names []TableName) *SchemaInfo {
+	keep := make(map[TableName]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+
+	subset := &SchemaInfo{}
+	for _, table := range s.Tables {
+		if keep[table.TableName] {
+			subset.Tables = append(subset.Tables, table)
+		}
+	}
+	for _, rel := range s.Relationships {
+		from := TableName{Schema: rel.FromSchema, Name: rel.FromTable}
+		to := TableName{Schema: rel.ToSchema, Name: rel.ToTable}
+		if keep[from] && keep[to] {
+			subset.Relationships = append(subset.Relationships, rel)
+		}
+	}
+	subset.Summary = generateSchemaSummary(subset.Tables, subset.Relationships)
+	return subset
+}
+
+func generateSchemaSummary(tables []TableInfo, relationships []TableRelationship) string {
+	var sb strings.Builder
+
+	views := 0
+	for _, table := range tables {
+		if table.Type != TableTypeTable {
+			views++
+		}
+	}
+	if views > 0 {
+		sb.WriteString(fmt.Sprintf("Database contains %d tables, %d views:\n\n", len(tables)-views, views))
+	} else {
+		sb.WriteString(fmt.Sprintf("Database contains %d tables:\n\n", len(tables)))
+	}
+
+	for _, table := range tables {
+		sb.WriteString(table.Summary())
+		sb.WriteString("\n")
+	}
+
+	if len(relationships) > 0 {
+		sb.WriteString(fmt.Sprintf("Relationships (%d):\n", len(relationships)))
+		for _, rel := range relationships {
+			sb.WriteString("  - " + rel.String() + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// Summary describes the table for the LLM: its description, columns with
+// their keys, defaults and descriptions, composite foreign keys, secondary
+// indexes, check constraints and, for views, the definition.
+func (t TableInfo) Summary() string {
+	var sb strings.Builder
+	switch t.Type {
+	case TableTypeView:
+		sb.WriteString(fmt.Sprintf("View: %s\n", t.TableName))
+	case TableTypeMaterializedView:
+		sb.WriteString(fmt.Sprintf("Materialized view: %s (%d rows)\n", t.TableName, t.RowCount))
+	default:
+		sb.WriteString(fmt.Sprintf("Table: %s (%d rows)\n", t.TableName, t.RowCount))
+	}
+	if t.Description != "" {
+		sb.WriteString("Description: " + oneLine(t.Description) + "\n")
+	}
+	// Columns of composite foreign keys are joined on together, so those
+	// keys are listed as a whole below the columns
+	var compositeKeys []ForeignKey
+	inCompositeKey := make(map[string]bool)
+	for _, fk := range t.ForeignKeys {
+		if len(fk.Columns) > 1 {
+			compositeKeys = append(compositeKeys, fk)
+			for _, name := range fk.Columns {
+				inCompositeKey[name] = true
+			}
+		}
+	}
+
+	sb.WriteString("Columns:\n")
+	for _, col := range t.Columns {
+		markers := []string{}
+		if col.PrimaryKey {
+			markers = append(markers, "PK")
+		}
+		if col.ForeignKey != "" && !inCompositeKey[col.Name] {
+			markers = append(markers, fmt.Sprintf("FK->%s", col.ForeignKey))
+		}
+		if !col.Nullable {
+			markers = append(markers, "NOT NULL")
+		}
+		if col.Default != "" {
+			markers = append(markers, "DEFAULT "+col.Default)
+		}
+
+		markerStr := ""
+		if len(markers) > 0 {
+			markerStr = fmt.Sprintf(" [%s]", strings.Join(markers, ", "))
+		}
+
+		descStr := ""
+		if col.Description != "" {
+			descStr = " -- " + oneLine(col.Description)
+		}
+
+		sb.WriteString(fmt.Sprintf("  - %s: %s%s%s\n", col.Name, col.Type, markerStr, descStr))
+	}
+	for _, fk := range compositeKeys {
+		sb.WriteString("Foreign key: " + fk.relationship(t.TableName).String() + "\n")
+	}
+
+	// The primary key is already marked on its columns
+	var indexes []string
+	for _, idx := range t.Indexes {
+		if idx.Primary {
+			continue
+		}
+		desc := fmt.Sprintf("%s (%s)", idx.Name, strings.Join(idx.Columns, ", "))
+		if idx.Unique {
+			desc += " UNIQUE"
+		}
+		if idx.Where != "" {
+			desc += " WHERE " + idx.Where
+		}
+		indexes = append(indexes, desc)
+	}
+	if len(indexes) > 0 {
+		sb.WriteString("Indexes: " + strings.Join(indexes, "; ") + "\n")
+	}
+
+	// Unique constraints show up as unique indexes
+	for _, con := range t.Constraints {
+		if con.Type == ConstraintCheck {
+			sb.WriteString(fmt.Sprintf("Check: %s\n", con.Definition))
+		}
+	}
+
+	if t.Definition != "" {
+		sb.WriteString("Definition: " + summarizeDefinition(t.Definition) + "\n")
+	}
+	return sb.String()
+}
+
+// maxDefinitionLength caps view definitions in the schema summary.
+const maxDefinitionLength = 600
+
+// summarizeDefinition puts a view definition on one line, shortening it
+// when it is very long.
+func summarizeDefinition(definition string) string {
+	definition = oneLine(definition)
+	if len(definition) > maxDefinitionLength {
+		definition = definition[:maxDefinitionLength] + " ..."
+	}
+	return definition
+}
+
+// oneLine collapses whitespace, including newlines, to single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,41 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+)
+
+// csvWriter writes a header line followed by one line per row. NULLs are
+// empty fields and binary values are base64 encoded.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []database.ResultColumn
+	record  []string
+}
+
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) WriteHeader(columns []database.ResultColumn) error {
+	cw.columns = columns
+	cw.record = make([]string, len(columns))
+	for i, col := range columns {
+		cw.record[i] = col.Name
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) WriteRow(values []interface{}) error {
+	for i, col := range cw.columns {
+		cw.record[i] = formatText(col, col.Normalize(values[i]))
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,100 @@
+// Package export writes query results as downloadable files. Rows are
+// written as they are read from the database, so exports are not limited by
+// max_results or by memory.
+package export
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+)
+
+// Writer encodes a result set. WriteHeader is called once, then WriteRow for
+// each row, then Close, which flushes anything buffered.
+type Writer interface {
+	WriteHeader(columns []database.ResultColumn) error
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// Format is an export file format.
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	New         func(w io.Writer) Writer
+}
+
+var formats = map[string]Format{
+	"csv":     {Name: "csv", Extension: "csv", ContentType: "text/csv; charset=utf-8", New: NewCSVWriter},
+	"ndjson":  {Name: "ndjson", Extension: "ndjson", ContentType: "application/x-ndjson", New: NewNDJSONWriter},
+	"xlsx":    {Name: "xlsx", Extension: "xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", New: NewXLSXWriter},
+	"parquet": {Name: "parquet", Extension: "parquet", ContentType: "application/vnd.apache.parquet", New: NewParquetWriter},
+}
+
+// LookupFormat returns the format with the given name ("jsonl" is accepted
+// for ndjson).
+func LookupFormat(name string) (Format, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "jsonl" {
+		name = "ndjson"
+	}
+	f, ok := formats[name]
+	return f, ok
+}
+
+// FormatNames lists the supported formats.
+func FormatNames() []string {
+	return []string{"csv", "xlsx", "ndjson", "parquet"}
+}
+
+// formatText renders a normalized value (see ResultColumn.Normalize) for
+// text-based formats. NULL is rendered as the empty string; callers that can
+// express NULL check for nil first.
+func formatText(col database.ResultColumn, v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case database.Decimal:
+		return string(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case time.Time:
+		return col.FormatTime(val)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// uniqueNames returns the column names, suffixing repeats ("id", "id_2") for
+// formats that key values by name.
+func uniqueNames(columns []database.ResultColumn) []string {
+	names := make([]string, len(columns))
+	taken := make(map[string]bool, len(columns))
+	for i, col := range columns {
+		name := col.Name
+		if name == "" {
+			name = "column"
+		}
+		unique := name
+		for n := 2; taken[unique]; n++ {
+			unique = name + "_" + strconv.Itoa(n)
+		}
+		taken[unique] = true
+		names[i] = unique
+	}
+	return names
+}
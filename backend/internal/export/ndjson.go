@@ -0,0 +1,59 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+)
+
+// ndjsonWriter writes one JSON object per line with keys in column order;
+// repeated column names get a numeric suffix.
+// Values are encoded exactly as in API responses (see
+// database.ResultColumn.JSONValue).
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []database.ResultColumn
+	keys    [][]byte
+}
+
+func NewNDJSONWriter(w io.Writer) Writer {
+	return &ndjsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (nw *ndjsonWriter) WriteHeader(columns []database.ResultColumn) error {
+	nw.columns = columns
+	nw.keys = make([][]byte, len(columns))
+	for i, name := range uniqueNames(columns) {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		nw.keys[i] = key
+	}
+	return nil
+}
+
+func (nw *ndjsonWriter) WriteRow(values []interface{}) error {
+	nw.w.WriteByte('{')
+	for i, col := range nw.columns {
+		if i > 0 {
+			nw.w.WriteByte(',')
+		}
+		nw.w.Write(nw.keys[i])
+		nw.w.WriteByte(':')
+
+		value, err := json.Marshal(col.JSONValue(values[i]))
+		if err != nil {
+			return err
+		}
+		nw.w.Write(value)
+	}
+	nw.w.WriteByte('}')
+	return nw.w.WriteByte('\n')
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+)
+
+const (
+	parquetRowGroupSize = 64 * 1024
+	parquetBatchSize    = 1024
+)
+
+// parquetWriter writes a flat schema with one optional column per result
+// column. Decimals with a known precision of up to 18 digits use the DECIMAL
+// logical type; other decimals, times of day and unknown types are strings.
+// Parquet orders the columns of a group by name, and duplicate names get a
+// numeric suffix.
+type parquetWriter struct {
+	out     io.Writer
+	w       *parquet.Writer
+	columns []parquetColumn
+	batch   []parquet.Row
+}
+
+type parquetColumn struct {
+	database.ResultColumn
+	index int // position in the parquet schema
+	node  parquet.Node
+}
+
+func NewParquetWriter(w io.Writer) Writer {
+	return &parquetWriter{out: w}
+}
+
+func (pw *parquetWriter) WriteHeader(columns []database.ResultColumn) error {
+	group := parquet.Group{}
+	names := uniqueNames(columns)
+	pw.columns = make([]parquetColumn, len(columns))
+	for i, col := range columns {
+		pw.columns[i] = parquetColumn{ResultColumn: col, node: parquetNode(col)}
+		group[names[i]] = parquet.Optional(pw.columns[i].node)
+	}
+
+	schema := parquet.NewSchema("results", group)
+	for i := range pw.columns {
+		leaf, _ := schema.Lookup(names[i])
+		pw.columns[i].index = leaf.ColumnIndex
+	}
+
+	pw.w = parquet.NewWriter(pw.out, schema,
+		parquet.Compression(&snappy.Codec{}),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.CreatedBy("chat-with-database", "", ""))
+	return nil
+}
+
+func parquetNode(col database.ResultColumn) parquet.Node {
+	switch col.Kind {
+	case database.KindInteger:
+		return parquet.Int(64)
+	case database.KindFloat:
+		return parquet.Leaf(parquet.DoubleType)
+	case database.KindBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case database.KindDecimal:
+		if col.Precision > 0 && col.Precision <= 18 {
+			return parquet.Decimal(int(col.Scale), int(col.Precision), parquet.Int64Type)
+		}
+		return parquet.String()
+	case database.KindTimestamp:
+		return parquet.Timestamp(parquet.Microsecond)
+	case database.KindDate:
+		return parquet.Date()
+	case database.KindBinary:
+		return parquet.Leaf(parquet.ByteArrayType)
+	default:
+		return parquet.String()
+	}
+}
+
+func (pw *parquetWriter) WriteRow(values []interface{}) error {
+	row := make(parquet.Row, len(pw.columns))
+	for i, col := range pw.columns {
+		v := col.Normalize(values[i])
+		if v == nil {
+			row[col.index] = parquet.NullValue().Level(0, 0, col.index)
+			continue
+		}
+		value, err := col.value(v)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", col.Name, err)
+		}
+		row[col.index] = value.Level(0, 1, col.index)
+	}
+
+	pw.batch = append(pw.batch, row)
+	if len(pw.batch) >= parquetBatchSize {
+		return pw.flushBatch()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flushBatch() error {
+	if len(pw.batch) == 0 {
+		return nil
+	}
+	_, err := pw.w.WriteRows(pw.batch)
+	pw.batch = pw.batch[:0]
+	return err
+}
+
+// value converts a normalized, non-NULL value to the column's physical type.
+func (col parquetColumn) value(v interface{}) (parquet.Value, error) {
+	switch col.node.Type().Kind() {
+	case parquet.Boolean:
+		switch val := v.(type) {
+		case bool:
+			return parquet.BooleanValue(val), nil
+		case int64:
+			return parquet.BooleanValue(val != 0), nil
+		}
+	case parquet.Double:
+		switch val := v.(type) {
+		case float64:
+			return parquet.DoubleValue(val), nil
+		case int64:
+			return parquet.DoubleValue(float64(val)), nil
+		}
+	case parquet.Int32:
+		// DATE: days since the Unix epoch
+		if t, ok := v.(time.Time); ok {
+			day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			return parquet.Int32Value(int32(day.Unix() / 86400)), nil
+		}
+	case parquet.Int64:
+		switch col.Kind {
+		case database.KindTimestamp:
+			if t, ok := v.(time.Time); ok {
+				return parquet.Int64Value(t.UnixMicro()), nil
+			}
+		case database.KindDecimal:
+			if n, ok := unscaledDecimal(formatText(col.ResultColumn, v), col.Scale); ok {
+				return parquet.Int64Value(n), nil
+			}
+		default:
+			if n, ok := v.(int64); ok {
+				return parquet.Int64Value(n), nil
+			}
+		}
+	case parquet.ByteArray:
+		if b, ok := v.([]byte); ok {
+			return parquet.ByteArrayValue(b), nil
+		}
+		return parquet.ByteArrayValue([]byte(formatText(col.ResultColumn, v))), nil
+	}
+	return parquet.Value{}, fmt.Errorf("cannot store %T value %v", v, v)
+}
+
+// unscaledDecimal returns s * 10^scale as an int64, rounding any extra
+// fractional digits toward zero.
+func unscaledDecimal(s string, scale int64) (int64, bool) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, false
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)))
+	n := new(big.Int).Quo(r.Num(), r.Denom())
+	if !n.IsInt64() {
+		return 0, false
+	}
+	return n.Int64(), true
+}
+
+func (pw *parquetWriter) Close() error {
+	if pw.w == nil {
+		return nil
+	}
+	if err := pw.flushBatch(); err != nil {
+		return err
+	}
+	return pw.w.Close()
+}
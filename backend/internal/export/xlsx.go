@@ -0,0 +1,136 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "Sheet1"
+
+// xlsxWriter writes a single worksheet. Numbers, booleans, dates and
+// timestamps become native cells; timestamps keep the wall-clock time the
+// database returned since Excel has no time zones. excelize spools rows to a
+// temporary file and the workbook is written out on Close.
+type xlsxWriter struct {
+	out      io.Writer
+	file     *excelize.File
+	stream   *excelize.StreamWriter
+	columns  []database.ResultColumn
+	row      int
+	cells    []interface{}
+	dateTime int
+	date     int
+}
+
+func NewXLSXWriter(w io.Writer) Writer {
+	return &xlsxWriter{out: w}
+}
+
+func (xw *xlsxWriter) WriteHeader(columns []database.ResultColumn) error {
+	xw.file = excelize.NewFile()
+
+	var err error
+	if xw.stream, err = xw.file.NewStreamWriter(xlsxSheet); err != nil {
+		return fmt.Errorf("failed to create worksheet: %w", err)
+	}
+
+	dateTimeFormat, dateFormat := "yyyy-mm-dd hh:mm:ss", "yyyy-mm-dd"
+	if xw.dateTime, err = xw.file.NewStyle(&excelize.Style{CustomNumFmt: &dateTimeFormat}); err != nil {
+		return err
+	}
+	if xw.date, err = xw.file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return err
+	}
+	bold, err := xw.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	xw.columns = columns
+	xw.cells = make([]interface{}, len(columns))
+	for i, col := range columns {
+		xw.cells[i] = excelize.Cell{StyleID: bold, Value: col.Name}
+	}
+	return xw.nextRow()
+}
+
+func (xw *xlsxWriter) WriteRow(values []interface{}) error {
+	if xw.row >= excelize.TotalRows {
+		return fmt.Errorf("result has more than %d rows, the XLSX limit; use csv, ndjson or parquet instead", excelize.TotalRows-1)
+	}
+	for i, col := range xw.columns {
+		xw.cells[i] = xw.cell(col, col.Normalize(values[i]))
+	}
+	return xw.nextRow()
+}
+
+func (xw *xlsxWriter) nextRow() error {
+	xw.row++
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.stream.SetRow(cell, xw.cells)
+}
+
+func (xw *xlsxWriter) cell(col database.ResultColumn, v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil, int64, bool:
+		return val
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return formatText(col, val)
+		}
+		return val
+	case database.Decimal:
+		// Excel stores numbers as doubles anyway
+		if f, err := strconv.ParseFloat(string(val), 64); err == nil {
+			return f
+		}
+		return string(val)
+	case time.Time:
+		if col.Kind == database.KindTime {
+			return formatText(col, val)
+		}
+		wall := time.Date(val.Year(), val.Month(), val.Day(), val.Hour(), val.Minute(), val.Second(), val.Nanosecond(), time.UTC)
+		if col.Kind == database.KindDate {
+			return excelize.Cell{StyleID: xw.date, Value: wall}
+		}
+		return excelize.Cell{StyleID: xw.dateTime, Value: wall}
+	default:
+		return truncateCell(formatText(col, val))
+	}
+}
+
+// truncateCell cuts s to the number of characters a cell can hold.
+func truncateCell(s string) string {
+	if len(s) <= excelize.TotalCellChars {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= excelize.TotalCellChars {
+		return s
+	}
+	return string(runes[:excelize.TotalCellChars])
+}
+
+func (xw *xlsxWriter) Close() error {
+	if xw.file == nil {
+		return nil
+	}
+	defer xw.file.Close()
+
+	if err := xw.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to write worksheet: %w", err)
+	}
+	if err := xw.file.Write(xw.out); err != nil {
+		return fmt.Errorf("failed to write workbook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrEmbeddingsUnavailable is returned by Embed when no embedding model is
+// configured or the provider has no embedding endpoint.
+var ErrEmbeddingsUnavailable = errors.New("embeddings are not available")
+
+// Embedder is implemented by providers that can turn text into vectors.
+// Embed returns one vector per input text, in order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	EmbeddingModel() string
+}
+
+type EmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type EmbedResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// Embed calls Ollama's /api/embed with the configured embedding model.
+func (c *OllamaClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.embeddingModel == "" {
+		return nil, ErrEmbeddingsUnavailable
+	}
+
+	jsonData, err := json.Marshal(EmbedRequest{Model: c.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.post(ctx, fmt.Sprintf("%s/api/embed", c.host), jsonData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result EmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(result.Embeddings), len(texts))
+	}
+
+	return result.Embeddings, nil
+}
+
+func (c *OllamaClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed calls the /v1/embeddings endpoint with the configured embedding
+// model.
+func (c *OpenAIClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.embeddingModel == "" {
+		return nil, ErrEmbeddingsUnavailable
+	}
+
+	jsonData, err := json.Marshal(OpenAIEmbeddingRequest{Model: c.embeddingModel, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/embeddings"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call openai-compatible server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai-compatible server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result OpenAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	for _, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai-compatible server returned %d embeddings for %d inputs", len(result.Data), len(texts))
+		}
+	}
+	return vectors, nil
+}
+
+func (c *OpenAIClient) EmbeddingModel() string {
+	return c.embeddingModel
+}
+
+// Embed forwards to the wrapped provider when it supports embeddings.
+func (t *timeoutProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder, ok := t.Provider.(Embedder)
+	if !ok {
+		return nil, ErrEmbeddingsUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return embedder.Embed(ctx, texts)
+}
+
+func (t *timeoutProvider) EmbeddingModel() string {
+	if embedder, ok := t.Provider.(Embedder); ok {
+		return embedder.EmbeddingModel()
+	}
+	return ""
+}
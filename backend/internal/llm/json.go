@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// JSONGenerator is implemented by providers that can constrain a reply to
+// JSON. schema is the JSON Schema the reply should follow; servers that
+// cannot enforce a schema still return a JSON object, so callers validate
+// the reply themselves.
+type JSONGenerator interface {
+	GenerateJSON(ctx context.Context, prompt, system string, schema json.RawMessage) (string, error)
+}
+
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"` // json_object or json_schema
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type OpenAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// GenerateJSON passes schema as Ollama's format, or asks for any JSON
+// object when schema is empty.
+func (c *OllamaClient) GenerateJSON(ctx context.Context, prompt, system string, schema json.RawMessage) (string, error) {
+	format := schema
+	if len(format) == 0 {
+		format = json.RawMessage(`"json"`)
+	}
+	return c.generate(ctx, prompt, system, format)
+}
+
+// GenerateJSON sets response_format to the schema, or to json_object when
+// schema is empty.
+func (c *OpenAIClient) GenerateJSON(ctx context.Context, prompt, system string, schema json.RawMessage) (string, error) {
+	format := &OpenAIResponseFormat{Type: "json_object"}
+	if len(schema) > 0 {
+		format = &OpenAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &OpenAIJSONSchema{Name: "response", Schema: schema},
+		}
+	}
+	return c.chat(ctx, buildMessages(prompt, system, nil), format)
+}
+
+// GenerateJSON forwards to the wrapped provider, or falls back to Generate
+// when it cannot constrain its output.
+func (t *timeoutProvider) GenerateJSON(ctx context.Context, prompt, system string, schema json.RawMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	if generator, ok := t.Provider.(JSONGenerator); ok {
+		return generator.GenerateJSON(ctx, prompt, system, schema)
+	}
+	return t.Provider.Generate(ctx, prompt, system)
+}
@@ -1,11 +1,14 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -15,14 +18,18 @@ type OllamaClient struct {
 	temperature float64
 	timeout     time.Duration
 	client      *http.Client
+
+	// embeddingModel is used by Embed; empty disables embeddings
+	embeddingModel string
 }
 
 type GenerateRequest struct {
-	Model       string  `json:"model"`
-	Prompt      string  `json:"prompt"`
-	Stream      bool    `json:"stream"`
-	Temperature float64 `json:"temperature"`
-	System      string  `json:"system,omitempty"`
+	Model       string          `json:"model"`
+	Prompt      string          `json:"prompt"`
+	Stream      bool            `json:"stream"`
+	Temperature float64         `json:"temperature"`
+	System      string          `json:"system,omitempty"`
+	Format      json.RawMessage `json:"format,omitempty"` // "json" or a JSON schema
 }
 
 type GenerateResponse struct {
@@ -64,13 +71,18 @@ func NewOllamaClient(host, model string, temperature float64, timeout int) *Olla
 	}
 }
 
-func (c *OllamaClient) Generate(prompt, system string) (string, error) {
+func (c *OllamaClient) Generate(ctx context.Context, prompt, system string) (string, error) {
+	return c.generate(ctx, prompt, system, nil)
+}
+
+func (c *OllamaClient) generate(ctx context.Context, prompt, system string, format json.RawMessage) (string, error) {
 	req := GenerateRequest{
 		Model:       c.model,
 		Prompt:      prompt,
 		Stream:      false,
 		Temperature: c.temperature,
 		System:      system,
+		Format:      format,
 	}
 
 	jsonData, err := json.Marshal(req)
@@ -78,13 +90,9 @@ func (c *OllamaClient) Generate(prompt, system string) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(
-		fmt.Sprintf("%s/api/generate", c.host),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := c.post(ctx, fmt.Sprintf("%s/api/generate", c.host), jsonData)
 	if err != nil {
-		return "", fmt.Errorf("failed to call ollama: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
@@ -101,7 +109,7 @@ func (c *OllamaClient) Generate(prompt, system string) (string, error) {
 	return result.Response, nil
 }
 
-func (c *OllamaClient) Chat(messages []ChatMessage) (string, error) {
+func (c *OllamaClient) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
 	req := ChatRequest{
 		Model:       c.model,
 		Messages:    messages,
@@ -114,13 +122,9 @@ func (c *OllamaClient) Chat(messages []ChatMessage) (string, error) {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(
-		fmt.Sprintf("%s/api/chat", c.host),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := c.post(ctx, fmt.Sprintf("%s/api/chat", c.host), jsonData)
 	if err != nil {
-		return "", fmt.Errorf("failed to call ollama: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
@@ -137,21 +141,114 @@ func (c *OllamaClient) Chat(messages []ChatMessage) (string, error) {
 	return result.Message.Content, nil
 }
 
-func (c *OllamaClient) GenerateWithContext(prompt, system string, conversationHistory []ChatMessage) (string, error) {
-	messages := make([]ChatMessage, 0, len(conversationHistory)+2)
-	
-	if system != "" {
-		messages = append(messages, ChatMessage{
-			Role:    "system",
-			Content: system,
-		})
+func (c *OllamaClient) GenerateWithContext(ctx context.Context, prompt, system string, conversationHistory []ChatMessage) (string, error) {
+	return c.Chat(ctx, buildMessages(prompt, system, conversationHistory))
+}
+
+func (c *OllamaClient) GenerateStream(ctx context.Context, prompt, system string, onToken func(string)) (string, error) {
+	req := GenerateRequest{
+		Model:       c.model,
+		Prompt:      prompt,
+		Stream:      true,
+		Temperature: c.temperature,
+		System:      system,
+	}
+
+	return c.stream(ctx, "/api/generate", req, func(line []byte) (string, bool, error) {
+		var chunk GenerateResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", false, err
+		}
+		return chunk.Response, chunk.Done, nil
+	}, onToken)
+}
+
+func (c *OllamaClient) ChatStream(ctx context.Context, messages []ChatMessage, onToken func(string)) (string, error) {
+	req := ChatRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Stream:      true,
+		Temperature: c.temperature,
+	}
+
+	return c.stream(ctx, "/api/chat", req, func(line []byte) (string, bool, error) {
+		var chunk ChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", false, err
+		}
+		return chunk.Message.Content, chunk.Done, nil
+	}, onToken)
+}
+
+func (c *OllamaClient) ModelInfo() ModelInfo {
+	return ModelInfo{
+		Provider: "ollama",
+		Model:    c.model,
+		Host:     c.host,
+	}
+}
+
+// post sends a JSON request bound to ctx, so cancelling the caller aborts
+// the HTTP call.
+func (c *OllamaClient) post(ctx context.Context, url string, jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call ollama: %w", err)
+	}
+	return resp, nil
+}
+
+// stream posts a streaming request and decodes Ollama's newline-delimited
+// JSON chunks with decode until a chunk reports done.
+func (c *OllamaClient) stream(ctx context.Context, path string, payload interface{}, decode func([]byte) (string, bool, error), onToken func(string)) (string, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := c.post(ctx, fmt.Sprintf("%s%s", c.host, path), jsonData)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var sb strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		token, done, err := decode(line)
+		if err != nil {
+			return sb.String(), fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if token != "" {
+			sb.WriteString(token)
+			if onToken != nil {
+				onToken(token)
+			}
+		}
+		if done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sb.String(), fmt.Errorf("failed to read stream: %w", err)
 	}
-	
-	messages = append(messages, conversationHistory...)
-	messages = append(messages, ChatMessage{
-		Role:    "user",
-		Content: prompt,
-	})
 
-	return c.Chat(messages)
+	return sb.String(), nil
 }
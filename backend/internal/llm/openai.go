@@ -0,0 +1,205 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIClient talks to any server implementing the OpenAI
+// /v1/chat/completions protocol (OpenAI, vLLM, llama.cpp server, ...).
+type OpenAIClient struct {
+	host        string
+	model       string
+	apiKey      string
+	temperature float64
+	timeout     time.Duration
+	client      *http.Client
+
+	// embeddingModel is used by Embed; empty disables embeddings
+	embeddingModel string
+}
+
+type OpenAIChatRequest struct {
+	Model          string                `json:"model"`
+	Messages       []ChatMessage         `json:"messages"`
+	Stream         bool                  `json:"stream"`
+	Temperature    float64               `json:"temperature"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type OpenAIChatResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int         `json:"index"`
+		Message      ChatMessage `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+type OpenAIChatChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role    string `json:"role,omitempty"`
+			Content string `json:"content,omitempty"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+func NewOpenAIClient(host, model, apiKey string, temperature float64, timeout int) *OpenAIClient {
+	return &OpenAIClient{
+		host:        strings.TrimRight(host, "/"),
+		model:       model,
+		apiKey:      apiKey,
+		temperature: temperature,
+		timeout:     time.Duration(timeout) * time.Second,
+		client: &http.Client{
+			Timeout: time.Duration(timeout) * time.Second,
+		},
+	}
+}
+
+func (c *OpenAIClient) Generate(ctx context.Context, prompt, system string) (string, error) {
+	return c.Chat(ctx, buildMessages(prompt, system, nil))
+}
+
+func (c *OpenAIClient) GenerateWithContext(ctx context.Context, prompt, system string, conversationHistory []ChatMessage) (string, error) {
+	return c.Chat(ctx, buildMessages(prompt, system, conversationHistory))
+}
+
+func (c *OpenAIClient) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	return c.chat(ctx, messages, nil)
+}
+
+func (c *OpenAIClient) chat(ctx context.Context, messages []ChatMessage, format *OpenAIResponseFormat) (string, error) {
+	resp, err := c.post(ctx, OpenAIChatRequest{
+		Model:          c.model,
+		Messages:       messages,
+		Stream:         false,
+		Temperature:    c.temperature,
+		ResponseFormat: format,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result OpenAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("openai response contained no choices")
+	}
+
+	return result.Choices[0].Message.Content, nil
+}
+
+func (c *OpenAIClient) GenerateStream(ctx context.Context, prompt, system string, onToken func(string)) (string, error) {
+	return c.ChatStream(ctx, buildMessages(prompt, system, nil), onToken)
+}
+
+func (c *OpenAIClient) ChatStream(ctx context.Context, messages []ChatMessage, onToken func(string)) (string, error) {
+	resp, err := c.post(ctx, OpenAIChatRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Stream:      true,
+		Temperature: c.temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// The stream is Server-Sent Events: "data: {...}" lines terminated by
+	// "data: [DONE]".
+	var sb strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk OpenAIChatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return sb.String(), fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			sb.WriteString(choice.Delta.Content)
+			if onToken != nil {
+				onToken(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sb.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+func (c *OpenAIClient) ModelInfo() ModelInfo {
+	return ModelInfo{
+		Provider: "openai",
+		Model:    c.model,
+		Host:     c.host,
+	}
+}
+
+func (c *OpenAIClient) post(ctx context.Context, payload OpenAIChatRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("/chat/completions"), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call openai-compatible server: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai-compatible server returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+// endpoint accepts hosts configured either with or without the /v1 suffix.
+func (c *OpenAIClient) endpoint(path string) string {
+	if strings.HasSuffix(c.host, "/v1") {
+		return c.host + path
+	}
+	return c.host + "/v1" + path
+}
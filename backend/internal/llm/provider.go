@@ -0,0 +1,123 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provider is implemented by every LLM backend the agent can talk to.
+// Cancelling ctx aborts the underlying HTTP request.
+type Provider interface {
+	Generate(ctx context.Context, prompt, system string) (string, error)
+	Chat(ctx context.Context, messages []ChatMessage) (string, error)
+	GenerateWithContext(ctx context.Context, prompt, system string, conversationHistory []ChatMessage) (string, error)
+
+	// Streaming variants call onToken for every chunk as it arrives and
+	// return the full concatenated text once the model is done.
+	GenerateStream(ctx context.Context, prompt, system string, onToken func(string)) (string, error)
+	ChatStream(ctx context.Context, messages []ChatMessage, onToken func(string)) (string, error)
+
+	ModelInfo() ModelInfo
+}
+
+type ModelInfo struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Host     string `json:"host"`
+}
+
+// ProviderConfig holds the settings needed to build any Provider.
+type ProviderConfig struct {
+	Provider    string
+	Host        string
+	Model       string
+	APIKey      string
+	Temperature float64
+	Timeout     int
+
+	// EmbeddingModel enables Embed (e.g. nomic-embed-text on Ollama)
+	EmbeddingModel string
+}
+
+// NewProvider builds the Provider selected by cfg.Provider.
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "ollama":
+		client := NewOllamaClient(cfg.Host, cfg.Model, cfg.Temperature, cfg.Timeout)
+		client.embeddingModel = cfg.EmbeddingModel
+		return client, nil
+	case "openai", "vllm", "llamacpp", "llama.cpp":
+		client := NewOpenAIClient(cfg.Host, cfg.Model, cfg.APIKey, cfg.Temperature, cfg.Timeout)
+		client.embeddingModel = cfg.EmbeddingModel
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unsupported llm provider: %s", cfg.Provider)
+	}
+}
+
+// WithCallTimeout wraps p so that every call gets its own deadline on top
+// of the caller's context. A zero timeout returns p unchanged.
+func WithCallTimeout(p Provider, timeout time.Duration) Provider {
+	if timeout <= 0 {
+		return p
+	}
+	return &timeoutProvider{Provider: p, timeout: timeout}
+}
+
+type timeoutProvider struct {
+	Provider
+	timeout time.Duration
+}
+
+func (t *timeoutProvider) Generate(ctx context.Context, prompt, system string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.Generate(ctx, prompt, system)
+}
+
+func (t *timeoutProvider) Chat(ctx context.Context, messages []ChatMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.Chat(ctx, messages)
+}
+
+func (t *timeoutProvider) GenerateWithContext(ctx context.Context, prompt, system string, conversationHistory []ChatMessage) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.GenerateWithContext(ctx, prompt, system, conversationHistory)
+}
+
+func (t *timeoutProvider) GenerateStream(ctx context.Context, prompt, system string, onToken func(string)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.GenerateStream(ctx, prompt, system, onToken)
+}
+
+func (t *timeoutProvider) ChatStream(ctx context.Context, messages []ChatMessage, onToken func(string)) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.Provider.ChatStream(ctx, messages, onToken)
+}
+
+// buildMessages prepends the system prompt and appends the user prompt to
+// the conversation history.
+func buildMessages(prompt, system string, conversationHistory []ChatMessage) []ChatMessage {
+	messages := make([]ChatMessage, 0, len(conversationHistory)+2)
+
+	if system != "" {
+		messages = append(messages, ChatMessage{
+			Role:    "system",
+			Content: system,
+		})
+	}
+
+	messages = append(messages, conversationHistory...)
+	messages = append(messages, ChatMessage{
+		Role:    "user",
+		Content: prompt,
+	})
+
+	return messages
+}
@@ -0,0 +1,406 @@
+package profiles
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gibranda/chat-with-database/internal/config"
+)
+
+const (
+	SourceConfig = "config"
+	SourceStore  = "store"
+)
+
+var (
+	ErrNotFound = errors.New("connection profile not found")
+	ErrExists   = errors.New("connection profile already exists")
+	ErrReadOnly = errors.New("connection profile is defined in config.yaml and cannot be changed")
+
+	validName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+)
+
+// Profile is a named set of connection settings.
+type Profile struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // postgres, mysql, sqlite3
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Database string `json:"database"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	SSLMode  string `json:"sslmode,omitempty"`
+	Path     string `json:"path,omitempty"` // for SQLite
+	// Postgres schemas / MySQL databases to introspect (names or globs)
+	Schemas        []string `json:"schemas,omitempty"`
+	ExcludeSchemas []string `json:"exclude_schemas,omitempty"`
+	Source         string   `json:"source"`
+	HasPassword    bool     `json:"has_password"`
+}
+
+// FromConfig builds a profile from a database section of config.yaml.
+func FromConfig(name string, c config.DatabaseConfig) Profile {
+	dbType := c.Type
+	if dbType == "sqlite" {
+		dbType = "sqlite3"
+	}
+	return Profile{
+		Name:           name,
+		Type:           dbType,
+		Host:           c.Host,
+		Port:           c.Port,
+		Database:       c.Name,
+		User:           c.User,
+		Password:       c.Password,
+		SSLMode:        c.SSLMode,
+		Path:           c.Path,
+		Schemas:        c.Schemas,
+		ExcludeSchemas: c.ExcludeSchemas,
+		Source:         SourceConfig,
+	}
+}
+
+// DatabaseConfig converts the profile back to connection settings.
+func (p Profile) DatabaseConfig() config.DatabaseConfig {
+	return config.DatabaseConfig{
+		Type:           p.Type,
+		Host:           p.Host,
+		Port:           p.Port,
+		Name:           p.Database,
+		User:           p.User,
+		Password:       p.Password,
+		SSLMode:        p.SSLMode,
+		Path:           p.Path,
+		Schemas:        p.Schemas,
+		ExcludeSchemas: p.ExcludeSchemas,
+	}
+}
+
+// Redacted returns a copy safe to send to clients.
+func (p Profile) Redacted() Profile {
+	p.HasPassword = p.Password != ""
+	p.Password = ""
+	return p
+}
+
+// Validate checks the fields required to open a connection.
+func (p Profile) Validate() error {
+	if !validName.MatchString(p.Name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' or '-'", p.Name)
+	}
+	switch p.Type {
+	case "postgres", "mysql":
+		if p.Database == "" {
+			return fmt.Errorf("profile %q: database is required", p.Name)
+		}
+	case "sqlite3":
+		if p.Path == "" {
+			return fmt.Errorf("profile %q: path is required", p.Name)
+		}
+	default:
+		return fmt.Errorf("profile %q: unsupported database type %q", p.Name, p.Type)
+	}
+	return nil
+}
+
+// storedProfile is the on-disk form, with the password encrypted.
+type storedProfile struct {
+	Profile
+	EncryptedPassword string `json:"encrypted_password,omitempty"`
+}
+
+// Store serves profiles from config.yaml together with profiles created at
+// runtime, which are persisted as JSON with AES-GCM encrypted passwords.
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	secret   string
+	gcm      cipher.AEAD // created on first use
+	config   map[string]Profile
+	profiles map[string]Profile
+}
+
+// NewStore loads the store at path. The encryption key is derived from
+// secret; when secret is empty a random key is generated on first use and
+// kept in path + ".key".
+func NewStore(path, secret string, configured map[string]config.DatabaseConfig) (*Store, error) {
+	s := &Store{
+		path:     path,
+		secret:   secret,
+		config:   make(map[string]Profile),
+		profiles: make(map[string]Profile),
+	}
+	for name, c := range configured {
+		p := FromConfig(name, c)
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid connection profile in config: %w", err)
+		}
+		s.config[name] = p
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns all profiles sorted by name. A stored profile never shadows
+// one from config.yaml.
+func (s *Store) List() []Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Profile, 0, len(s.config)+len(s.profiles))
+	for _, p := range s.config {
+		list = append(list, p)
+	}
+	for name, p := range s.profiles {
+		if _, shadowed := s.config[name]; !shadowed {
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Get returns the named profile with its password decrypted.
+func (s *Store) Get(name string) (Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if p, ok := s.config[name]; ok {
+		return p, nil
+	}
+	if p, ok := s.profiles[name]; ok {
+		return p, nil
+	}
+	return Profile{}, ErrNotFound
+}
+
+// Create adds a new profile and persists the store.
+func (s *Store) Create(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.config[p.Name]; ok {
+		return ErrExists
+	}
+	if _, ok := s.profiles[p.Name]; ok {
+		return ErrExists
+	}
+	p.Source = SourceStore
+	s.profiles[p.Name] = p
+	return s.saveLocked()
+}
+
+// Update replaces a stored profile. An empty password keeps the current one.
+func (s *Store) Update(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.config[p.Name]; ok {
+		return ErrReadOnly
+	}
+	current, ok := s.profiles[p.Name]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.Password == "" {
+		p.Password = current.Password
+	}
+	p.Source = SourceStore
+	s.profiles[p.Name] = p
+	return s.saveLocked()
+}
+
+// Delete removes a stored profile.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.config[name]; ok {
+		return ErrReadOnly
+	}
+	if _, ok := s.profiles[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.profiles, name)
+	return s.saveLocked()
+}
+
+func (s *Store) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profile store: %w", err)
+	}
+
+	var stored []storedProfile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse profile store: %w", err)
+	}
+
+	for _, sp := range stored {
+		p := sp.Profile
+		if sp.EncryptedPassword != "" {
+			p.Password, err = s.decrypt(sp.EncryptedPassword)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt password for profile %q (was the secret changed?): %w", p.Name, err)
+			}
+		}
+		p.Source = SourceStore
+		p.HasPassword = false
+		s.profiles[p.Name] = p
+	}
+	return nil
+}
+
+// saveLocked writes the store atomically. The caller must hold s.mu.
+func (s *Store) saveLocked() error {
+	stored := make([]storedProfile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		sp := storedProfile{Profile: p}
+		if p.Password != "" {
+			enc, err := s.encrypt(p.Password)
+			if err != nil {
+				return err
+			}
+			sp.EncryptedPassword = enc
+		}
+		sp.Password = ""
+		stored = append(stored, sp)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode profile store: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create profile store directory: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write profile store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write profile store: %w", err)
+	}
+	return nil
+}
+
+// aead returns the cipher, loading or creating the key on first use.
+func (s *Store) aead() (cipher.AEAD, error) {
+	if s.gcm != nil {
+		return s.gcm, nil
+	}
+
+	key, err := loadKey(s.path, s.secret)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	s.gcm, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return s.gcm, nil
+}
+
+func (s *Store) encrypt(plaintext string) (string, error) {
+	gcm, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Store) decrypt(encoded string) (string, error) {
+	gcm, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// loadKey derives a 256-bit key from secret, or reads (creating if needed)
+// a random key stored next to the profile store.
+func loadKey(storePath, secret string) ([]byte, error) {
+	if secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		return sum[:], nil
+	}
+
+	keyPath := storePath + ".key"
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid profile key file %s", keyPath)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read profile key file: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate profile key: %w", err)
+	}
+	if dir := filepath.Dir(keyPath); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create profile store directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(keyPath, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write profile key file: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,258 @@
+// Package prompts holds the LLM prompt templates and the localized messages
+// shown to users. Defaults are embedded in the binary; a directory given to
+// Load can override them file by file and add languages.
+package prompts
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl messages/*.json
+var defaults embed.FS
+
+// fallbackLanguage supplies messages missing from other catalogs.
+const fallbackLanguage = "en"
+
+// Data is what prompt templates can refer to; each prompt uses the fields
+// that apply to it.
+type Data struct {
+	Language    string // answer language name, e.g. "English"
+	Schema      string
+	Tables      string // table names, comma separated
+	Hints       string // column value hints, possibly empty
+	History     string // earlier turns, possibly empty
+	Question    string
+	Plan        string
+	SQL         string
+	Error       string
+	Results     string // preview rows as JSON
+	RowCount    string
+	PreviewRows int
+	Tools       string
+	Transcript  string
+	Remaining   int
+	Prompt      string // a prompt being retried
+	Reply       string // the model's rejected reply
+}
+
+// Set is a loaded collection of prompt templates and message catalogs.
+type Set struct {
+	templates map[string]*template.Template // by file name without .tmpl, e.g. "answer.id"
+	builtin   map[string]*template.Template
+	catalogs  map[string]map[string]string // by language code
+}
+
+// Language is the language answers are given in: the name used in prompts
+// and the catalog messages are taken from.
+type Language struct {
+	Code string
+	Name string
+	set  *Set
+}
+
+// Default returns the embedded templates and messages.
+func Default() *Set {
+	set, err := Load("")
+	if err != nil {
+		panic(err) // the embedded files are known to parse
+	}
+	return set
+}
+
+// Load reads the embedded defaults, then the overrides in dir: prompt
+// templates named <prompt>.tmpl, or <prompt>.<lang>.tmpl for one language,
+// and message catalogs named messages/<lang>.json. An empty dir loads the
+// defaults only.
+func Load(dir string) (*Set, error) {
+	set := &Set{catalogs: make(map[string]map[string]string)}
+
+	builtin, err := loadTemplates(defaults, "templates")
+	if err != nil {
+		return nil, err
+	}
+	set.builtin = builtin
+	set.templates = make(map[string]*template.Template, len(builtin))
+	for name, tmpl := range builtin {
+		set.templates[name] = tmpl
+	}
+	if err := set.loadCatalogs(defaults, "messages"); err != nil {
+		return nil, err
+	}
+
+	if dir == "" {
+		return set, nil
+	}
+	custom := os.DirFS(dir)
+	overrides, err := loadTemplates(custom, ".")
+	if err != nil {
+		return nil, err
+	}
+	for name, tmpl := range overrides {
+		base, _, _ := strings.Cut(name, ".")
+		if builtin[base] == nil {
+			return nil, fmt.Errorf("unknown prompt template %s.tmpl in %s", name, dir)
+		}
+		set.templates[name] = tmpl
+	}
+	// A custom prompt replaces the built-in language versions of it too
+	for name := range set.templates {
+		base, _, _ := strings.Cut(name, ".")
+		if name != base && overrides[base] != nil && overrides[name] == nil {
+			delete(set.templates, name)
+		}
+	}
+	if _, err := fs.Stat(custom, "messages"); err == nil {
+		if err := set.loadCatalogs(custom, "messages"); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+func loadTemplates(fsys fs.FS, dir string) (map[string]*template.Template, error) {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]*template.Template, len(paths))
+	for _, file := range paths {
+		text, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		}
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", file, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// loadCatalogs merges every <lang>.json in dir over the catalogs loaded so
+// far. Keys must be ones the English catalog defines.
+func (s *Set) loadCatalogs(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range paths {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read message catalog: %w", err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("failed to parse message catalog %s: %w", file, err)
+		}
+
+		code := normalizeCode(strings.TrimSuffix(path.Base(file), ".json"))
+		if english := s.catalogs[fallbackLanguage]; english != nil {
+			for key := range messages {
+				if _, ok := english[key]; !ok {
+					return fmt.Errorf("unknown message %q in %s", key, file)
+				}
+			}
+		}
+		catalog := s.catalogs[code]
+		if catalog == nil {
+			catalog = make(map[string]string, len(messages))
+			s.catalogs[code] = catalog
+		}
+		for key, text := range messages {
+			catalog[key] = text
+		}
+	}
+	return nil
+}
+
+// Language resolves a language code ("en", "id", "en-US") or the name of a
+// language with a catalog ("English"). Languages without a catalog still
+// get answers in that language, with English messages.
+func (s *Set) Language(lang string) Language {
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		lang = fallbackLanguage
+	}
+
+	code := normalizeCode(lang)
+	if _, ok := s.catalogs[code]; !ok {
+		for c, catalog := range s.catalogs {
+			if strings.EqualFold(catalog["language_name"], lang) {
+				code = c
+				break
+			}
+		}
+	}
+
+	l := Language{Code: code, Name: lang, set: s}
+	if name := s.catalogs[code]["language_name"]; name != "" {
+		l.Name = name
+	}
+	return l
+}
+
+func normalizeCode(lang string) string {
+	code, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	code, _, _ = strings.Cut(code, "_")
+	return code
+}
+
+// Message formats the catalog message key with args, falling back to the
+// English message.
+func (l Language) Message(key string, args ...interface{}) string {
+	text, ok := l.set.catalogs[l.Code][key]
+	if !ok {
+		if text, ok = l.set.catalogs[fallbackLanguage][key]; !ok {
+			text = key
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Render executes the prompt template name for lang, preferring a
+// language-specific version. A custom template that fails to execute is
+// logged and replaced by the built-in one.
+func (s *Set) Render(name string, lang Language, data Data) string {
+	data.Language = lang.Name
+
+	tmpl := s.templates[name+"."+lang.Code]
+	if tmpl == nil {
+		tmpl = s.templates[name]
+	}
+	out, err := execute(tmpl, data)
+	if err != nil {
+		log.Printf("Prompt template %s failed, using the built-in one: %v", name, err)
+		fallback := s.builtin[name+"."+lang.Code]
+		if fallback == nil {
+			fallback = s.builtin[name]
+		}
+		out, _ = execute(fallback, data)
+	}
+	return strings.TrimRight(out, "\n")
+}
+
+func execute(tmpl *template.Template, data Data) (string, error) {
+	if tmpl == nil {
+		return "", fmt.Errorf("no such prompt template")
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
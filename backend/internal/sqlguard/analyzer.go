@@ -0,0 +1,617 @@
+// Package sqlguard classifies SQL statements so the agent can decide
+// whether generated SQL is safe to run, without relying on substring
+// matching.
+package sqlguard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	// SeverityBlocked findings make a query unsafe to run at all.
+	SeverityBlocked Severity = "blocked"
+	// SeverityWrite findings make a query modify data or take write locks.
+	SeverityWrite Severity = "write"
+)
+
+// NodeRef points at the part of the query a finding is about.
+type NodeRef struct {
+	Kind     string `json:"kind"`
+	Text     string `json:"text"`
+	Position int    `json:"position"`
+}
+
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+	Node     NodeRef  `json:"node"`
+}
+
+// Verdict is the result of analysing one query.
+type Verdict struct {
+	Allowed       bool      `json:"allowed"`
+	ReadOnly      bool      `json:"read_only"`
+	StatementType string    `json:"statement_type"`
+	Reason        string    `json:"reason,omitempty"`
+	Offending     *NodeRef  `json:"offending,omitempty"`
+	Findings      []Finding `json:"findings,omitempty"`
+}
+
+// Violation is the error form of a rejecting Verdict.
+type Violation struct {
+	Verdict Verdict
+}
+
+func (v *Violation) Error() string {
+	if v.Verdict.Offending == nil {
+		return v.Verdict.Reason
+	}
+	return fmt.Sprintf("%s (at position %d: %q)", v.Verdict.Reason, v.Verdict.Offending.Position, v.Verdict.Offending.Text)
+}
+
+// Err returns a *Violation when the query is not allowed, nil otherwise.
+func (v Verdict) Err() error {
+	if v.Allowed {
+		return nil
+	}
+	return &Violation{Verdict: v}
+}
+
+// ReadOnlyErr returns a *Violation when the query is not allowed or is not
+// read-only, nil otherwise.
+func (v Verdict) ReadOnlyErr() error {
+	if v.Allowed && v.ReadOnly {
+		return nil
+	}
+	return &Violation{Verdict: v}
+}
+
+// Node is either a single token or a parenthesised group of nodes.
+type Node struct {
+	Token    Token
+	Group    bool
+	Children []*Node
+}
+
+func (n *Node) isWord(kw string) bool {
+	return !n.Group && n.Token.IsWord(kw)
+}
+
+func (n *Node) ref(kind string) NodeRef {
+	text := n.Token.Value
+	if n.Group {
+		text = "("
+	}
+	return NodeRef{Kind: kind, Text: text, Position: n.Token.Pos}
+}
+
+// statementKeywords start a statement (or a sub-statement inside parentheses).
+var statementKeywords = map[string]bool{
+	"SELECT": true, "WITH": true, "VALUES": true, "TABLE": true,
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+	"REPLACE": true, "UPSERT": true,
+}
+
+var dataModifying = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true,
+	"REPLACE": true, "UPSERT": true,
+}
+
+var ddlStatements = map[string]bool{
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true,
+	"RENAME": true, "GRANT": true, "REVOKE": true, "COMMENT": true,
+	"REINDEX": true, "VACUUM": true, "CLUSTER": true, "REFRESH": true,
+	"ANALYZE": true, "OPTIMIZE": true, "REPAIR": true, "SECURITY": true,
+	"IMPORT": true, "DISCARD": true,
+}
+
+var sessionStatements = map[string]bool{
+	"SET": true, "RESET": true, "BEGIN": true, "START": true, "COMMIT": true,
+	"ROLLBACK": true, "SAVEPOINT": true, "RELEASE": true, "END": true,
+	"LOCK": true, "UNLOCK": true, "CALL": true, "DO": true, "EXECUTE": true,
+	"EXEC": true, "PREPARE": true, "DEALLOCATE": true, "HANDLER": true,
+	"LISTEN": true, "NOTIFY": true, "UNLISTEN": true, "KILL": true,
+	"SHUTDOWN": true, "FLUSH": true, "INSTALL": true, "UNINSTALL": true,
+	"USE": true, "CHECKPOINT": true, "PURGE": true, "RESTART": true,
+}
+
+// readOnlyPragmas report on the database; their argument names what to
+// report on rather than a new setting.
+var readOnlyPragmas = map[string]bool{
+	"table_info": true, "table_xinfo": true, "table_list": true, "index_list": true,
+	"index_info": true, "index_xinfo": true, "foreign_key_list": true,
+	"foreign_key_check": true, "integrity_check": true, "quick_check": true,
+}
+
+// actionPragmas change the database even without an argument.
+var actionPragmas = map[string]bool{
+	"optimize": true, "shrink_memory": true, "incremental_vacuum": true,
+	"wal_checkpoint": true,
+}
+
+// writeFunctions change database state when called.
+var writeFunctions = map[string]bool{
+	// postgres sequences, large objects and notifications
+	"nextval": true, "setval": true, "lo_create": true, "lo_creat": true,
+	"lo_unlink": true, "lo_put": true, "lo_from_bytea": true, "lo_truncate": true,
+	"lo_truncate64": true, "lowrite": true, "pg_notify": true,
+}
+
+// dangerousFunctions can sleep, read or write server files, run arbitrary
+// query strings or affect other sessions.
+var dangerousFunctions = map[string]bool{
+	// postgres
+	"pg_sleep": true, "pg_sleep_for": true, "pg_sleep_until": true,
+	"pg_read_file": true, "pg_read_binary_file": true, "pg_ls_dir": true,
+	"pg_stat_file": true, "pg_file_write": true, "pg_terminate_backend": true,
+	"pg_cancel_backend": true, "pg_reload_conf": true, "pg_rotate_logfile": true,
+	"pg_promote": true, "pg_advisory_lock": true, "pg_advisory_xact_lock": true,
+	"lo_import": true, "lo_export": true, "dblink": true, "dblink_exec": true,
+	"dblink_connect": true, "set_config": true, "query_to_xml": true,
+	"query_to_xml_and_xmlschema": true, "query_to_xmlschema": true,
+	// mysql
+	"sleep": true, "benchmark": true, "load_file": true, "get_lock": true,
+	"sys_exec": true, "sys_eval": true, "master_pos_wait": true,
+	"source_pos_wait": true,
+	// sqlite
+	"load_extension": true, "writefile": true, "readfile": true, "edit": true,
+	"fts3_tokenizer": true,
+}
+
+// Analyze tokenizes and classifies query for dialect (postgres, mysql or
+// sqlite3) and returns a verdict describing whether it may run.
+func Analyze(dialect, query string) Verdict {
+	tokens, err := Tokenize(dialect, query)
+	if err != nil {
+		return syntaxVerdict(err)
+	}
+
+	nodes, err := buildTree(tokens)
+	if err != nil {
+		return syntaxVerdict(err)
+	}
+
+	statements := splitStatements(nodes)
+	if len(statements) == 0 {
+		return Verdict{Reason: "empty query"}
+	}
+
+	a := &analyzer{dialect: dialect}
+	if len(statements) > 1 {
+		a.block("multiple_statements", "only a single statement is allowed", statements[1][0].ref("statement"))
+	}
+
+	statementType := a.statement(statements[0], "statement")
+	return a.verdict(statementType)
+}
+
+// SingleStatement returns a *Violation unless query holds at most one
+// statement. It checks nothing else, so callers can rely on it even when
+// full validation is turned off: a second statement could end the
+// transaction the first one runs in.
+func SingleStatement(dialect, query string) error {
+	tokens, err := Tokenize(dialect, query)
+	if err != nil {
+		return &Violation{Verdict: syntaxVerdict(err)}
+	}
+	nodes, err := buildTree(tokens)
+	if err != nil {
+		return &Violation{Verdict: syntaxVerdict(err)}
+	}
+
+	a := &analyzer{dialect: dialect}
+	if statements := splitStatements(nodes); len(statements) > 1 {
+		a.block("multiple_statements", "only a single statement is allowed", statements[1][0].ref("statement"))
+	} else if sep := nestedSemicolon(nodes); sep != nil {
+		a.block("multiple_statements", "statement separator inside parentheses", sep.ref("token"))
+	}
+	return a.verdict("").Err()
+}
+
+// nestedSemicolon returns the first semicolon inside parentheses, or nil.
+func nestedSemicolon(nodes []*Node) *Node {
+	for _, n := range nodes {
+		if !n.Group {
+			continue
+		}
+		for _, child := range n.Children {
+			if !child.Group && child.Token.Kind == TokenSemicolon {
+				return child
+			}
+		}
+		if sep := nestedSemicolon(n.Children); sep != nil {
+			return sep
+		}
+	}
+	return nil
+}
+
+func syntaxVerdict(err error) Verdict {
+	node := NodeRef{Kind: "syntax"}
+	msg := err.Error()
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		node.Position = syntaxErr.Pos
+		msg = syntaxErr.Msg
+	}
+	return Verdict{
+		Reason:    msg,
+		Offending: &node,
+		Findings: []Finding{{
+			Severity: SeverityBlocked,
+			Rule:     "syntax",
+			Message:  msg,
+			Node:     node,
+		}},
+	}
+}
+
+// buildTree nests tokens by parentheses.
+func buildTree(tokens []Token) ([]*Node, error) {
+	root := &Node{Group: true}
+	stack := []*Node{root}
+
+	for _, t := range tokens {
+		top := stack[len(stack)-1]
+		switch t.Kind {
+		case TokenLParen:
+			group := &Node{Token: t, Group: true}
+			top.Children = append(top.Children, group)
+			stack = append(stack, group)
+		case TokenRParen:
+			if len(stack) == 1 {
+				return nil, &SyntaxError{Msg: "unbalanced ')'", Pos: t.Pos}
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.Children = append(top.Children, &Node{Token: t})
+		}
+	}
+
+	if len(stack) > 1 {
+		return nil, &SyntaxError{Msg: "unclosed '('", Pos: stack[len(stack)-1].Token.Pos}
+	}
+	return root.Children, nil
+}
+
+// splitStatements splits top-level nodes on semicolons, dropping empty
+// statements (so a trailing semicolon is fine).
+func splitStatements(nodes []*Node) [][]*Node {
+	var statements [][]*Node
+	var current []*Node
+	for _, n := range nodes {
+		if !n.Group && n.Token.Kind == TokenSemicolon {
+			if len(current) > 0 {
+				statements = append(statements, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, n)
+	}
+	if len(current) > 0 {
+		statements = append(statements, current)
+	}
+	return statements
+}
+
+type analyzer struct {
+	dialect  string
+	findings []Finding
+}
+
+func (a *analyzer) block(rule, msg string, node NodeRef) {
+	a.findings = append(a.findings, Finding{Severity: SeverityBlocked, Rule: rule, Message: msg, Node: node})
+}
+
+func (a *analyzer) write(rule, msg string, node NodeRef) {
+	a.findings = append(a.findings, Finding{Severity: SeverityWrite, Rule: rule, Message: msg, Node: node})
+}
+
+func (a *analyzer) verdict(statementType string) Verdict {
+	v := Verdict{
+		Allowed:       true,
+		ReadOnly:      true,
+		StatementType: statementType,
+		Findings:      a.findings,
+	}
+
+	var first *Finding
+	for i := range a.findings {
+		f := &a.findings[i]
+		switch f.Severity {
+		case SeverityBlocked:
+			if v.Allowed {
+				first = f
+			}
+			v.Allowed = false
+			v.ReadOnly = false
+		case SeverityWrite:
+			if first == nil {
+				first = f
+			}
+			v.ReadOnly = false
+		}
+	}
+
+	if first != nil {
+		v.Reason = first.Message
+		node := first.Node
+		v.Offending = &node
+	}
+	return v
+}
+
+// statement classifies one statement and records findings for it and for
+// everything nested inside it. kind describes where the statement appears
+// (statement, cte, subquery) and is used in finding messages.
+func (a *analyzer) statement(nodes []*Node, kind string) string {
+	for len(nodes) > 0 && !nodes[0].Group && nodes[0].Token.Kind == TokenExecComment {
+		a.block("executable_comment", "MySQL executable comments are not allowed", nodes[0].ref("comment"))
+		nodes = nodes[1:]
+	}
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	first := nodes[0]
+	if first.Group {
+		// (SELECT ...) UNION (SELECT ...)
+		statementType := a.statement(first.Children, kind)
+		a.scan(nodes[1:])
+		return statementType
+	}
+
+	keyword := first.Token.Upper()
+	switch {
+	case keyword == "SELECT" || keyword == "VALUES" || keyword == "TABLE":
+		a.selectClauses(nodes)
+		a.scan(nodes[1:])
+		return "SELECT"
+
+	case keyword == "WITH":
+		return a.with(nodes, kind)
+
+	case keyword == "EXPLAIN" || keyword == "DESCRIBE" || keyword == "DESC":
+		return a.explain(nodes, kind)
+
+	case keyword == "SHOW":
+		a.scan(nodes[1:])
+		return "SHOW"
+
+	case keyword == "PRAGMA":
+		a.pragma(nodes, kind)
+		return "PRAGMA"
+
+	case dataModifying[keyword]:
+		a.dataModification(nodes, keyword, kind)
+		return keyword
+
+	case ddlStatements[keyword]:
+		a.block("ddl", fmt.Sprintf("%s statements are not allowed", keyword), first.ref(kind))
+		return keyword
+
+	case keyword == "COPY":
+		if n := findWord(nodes, "PROGRAM"); n != nil {
+			a.block("copy_program", "COPY ... PROGRAM runs shell commands on the server", n.ref("clause"))
+		} else {
+			a.block("copy", "COPY reads or writes server files", first.ref(kind))
+		}
+		return keyword
+
+	case keyword == "ATTACH" || keyword == "DETACH":
+		a.block("attach_database", fmt.Sprintf("%s DATABASE is not allowed", keyword), first.ref(kind))
+		return keyword
+
+	case keyword == "LOAD":
+		a.block("load", "LOAD statements read server files or libraries", first.ref(kind))
+		return keyword
+
+	case sessionStatements[keyword]:
+		a.block("session_control", fmt.Sprintf("%s statements are not allowed", keyword), first.ref(kind))
+		return keyword
+
+	default:
+		a.block("unknown_statement", fmt.Sprintf("unrecognized statement %q", first.Token.Value), first.ref(kind))
+		return keyword
+	}
+}
+
+func (a *analyzer) dataModification(nodes []*Node, keyword, kind string) {
+	first := nodes[0]
+	switch kind {
+	case "cte":
+		a.write("data_modifying_cte", fmt.Sprintf("%s inside a WITH clause modifies data", keyword), first.ref(kind))
+	case "subquery":
+		a.write("data_modifying_subquery", fmt.Sprintf("%s inside a subquery modifies data", keyword), first.ref(kind))
+	default:
+		a.write("data_modification", fmt.Sprintf("%s modifies data", keyword), first.ref(kind))
+	}
+
+	if (keyword == "UPDATE" || keyword == "DELETE") && findWord(nodes, "WHERE") == nil {
+		a.block("unbounded_write", fmt.Sprintf("%s without a WHERE clause affects every row", keyword), first.ref(kind))
+	}
+
+	a.scan(nodes[1:])
+}
+
+// selectClauses flags top-level clauses of a SELECT that write or lock.
+func (a *analyzer) selectClauses(nodes []*Node) {
+	for i, n := range nodes {
+		if n.Group {
+			continue
+		}
+		next := nodeAt(nodes, i+1)
+
+		switch {
+		case n.isWord("INTO"):
+			if next != nil && (next.isWord("OUTFILE") || next.isWord("DUMPFILE")) {
+				a.block("file_write", "SELECT ... INTO "+next.Token.Upper()+" writes server files", next.ref("clause"))
+			} else if next != nil && !next.Group && next.Token.Kind == TokenParam {
+				// MySQL SELECT ... INTO @variable only sets a session variable
+			} else {
+				a.write("select_into", "SELECT ... INTO creates or fills a table", n.ref("clause"))
+			}
+
+		case n.isWord("FOR") && next != nil &&
+			(next.isWord("UPDATE") || next.isWord("SHARE") || next.isWord("NO") || next.isWord("KEY")):
+			a.write("locking_read", "FOR "+next.Token.Upper()+" takes row locks", n.ref("clause"))
+
+		case n.isWord("LOCK") && next != nil && next.isWord("IN"):
+			a.write("locking_read", "LOCK IN SHARE MODE takes row locks", n.ref("clause"))
+		}
+	}
+}
+
+// with walks WITH [RECURSIVE] name [(cols)] AS [NOT] [MATERIALIZED] (body), ...
+// and then classifies the main statement.
+func (a *analyzer) with(nodes []*Node, kind string) string {
+	i := 1
+	if n := nodeAt(nodes, i); n != nil && n.isWord("RECURSIVE") {
+		i++
+	}
+
+	for {
+		if nodeAt(nodes, i) == nil {
+			a.block("syntax", "incomplete WITH clause", nodes[0].ref("cte"))
+			return "WITH"
+		}
+		i++ // CTE name
+		if n := nodeAt(nodes, i); n != nil && n.Group {
+			i++ // column list
+		}
+		if n := nodeAt(nodes, i); n == nil || !n.isWord("AS") {
+			a.block("syntax", "expected AS in WITH clause", nodes[0].ref("cte"))
+			return "WITH"
+		}
+		i++
+		if n := nodeAt(nodes, i); n != nil && n.isWord("NOT") {
+			i++
+		}
+		if n := nodeAt(nodes, i); n != nil && n.isWord("MATERIALIZED") {
+			i++
+		}
+
+		body := nodeAt(nodes, i)
+		if body == nil || !body.Group {
+			a.block("syntax", "expected parenthesised query in WITH clause", nodes[0].ref("cte"))
+			return "WITH"
+		}
+		a.statement(body.Children, "cte")
+		i++
+
+		if n := nodeAt(nodes, i); n != nil && !n.Group && n.Token.Kind == TokenComma {
+			i++
+			continue
+		}
+		break
+	}
+
+	if i >= len(nodes) {
+		a.block("syntax", "WITH clause without a main statement", nodes[0].ref("cte"))
+		return "WITH"
+	}
+	return a.statement(nodes[i:], kind)
+}
+
+// pragma flags PRAGMA statements that change settings or the database:
+// PRAGMA x = y and PRAGMA x(y) set x unless x is a pragma that only reports
+// (table_info(t)), and some pragmas act even without an argument.
+func (a *analyzer) pragma(nodes []*Node, kind string) {
+	first := nodes[0]
+	i := 1
+	if n := nodeAt(nodes, i+1); n != nil && !n.Group && n.Token.Kind == TokenDot {
+		i += 2 // schema.name
+	}
+	name := ""
+	if n := nodeAt(nodes, i); n != nil && !n.Group {
+		name = strings.ToLower(n.Token.Value)
+	}
+
+	arg := nodeAt(nodes, i+1)
+	switch {
+	case arg == nil:
+		if actionPragmas[name] {
+			a.write("pragma_action", fmt.Sprintf("PRAGMA %s changes the database", name), first.ref(kind))
+		}
+	case !readOnlyPragmas[name]:
+		a.write("pragma_assignment", "PRAGMA assignments change database settings", first.ref(kind))
+	}
+	a.scan(nodes[1:])
+}
+
+// explain skips EXPLAIN options and classifies the explained statement, if
+// any. The statement is treated as if it runs, since EXPLAIN ANALYZE does.
+func (a *analyzer) explain(nodes []*Node, kind string) string {
+	for i := 1; i < len(nodes); i++ {
+		n := nodes[i]
+		if n.Group {
+			if len(n.Children) > 0 && !n.Children[0].Group && statementKeywords[n.Children[0].Token.Upper()] {
+				a.statement(nodes[i:], kind)
+				return "EXPLAIN"
+			}
+			continue // postgres option list
+		}
+		if statementKeywords[n.Token.Upper()] {
+			a.statement(nodes[i:], kind)
+			return "EXPLAIN"
+		}
+	}
+
+	// DESCRIBE table / EXPLAIN table
+	a.scan(nodes[1:])
+	return "EXPLAIN"
+}
+
+// scan walks nested nodes looking for sub-statements, dangerous function
+// calls and executable comments.
+func (a *analyzer) scan(nodes []*Node) {
+	for i, n := range nodes {
+		if n.Group {
+			if len(n.Children) > 0 && !n.Children[0].Group && statementKeywords[n.Children[0].Token.Upper()] {
+				a.statement(n.Children, "subquery")
+			} else {
+				a.scan(n.Children)
+			}
+			continue
+		}
+
+		switch n.Token.Kind {
+		case TokenExecComment:
+			a.block("executable_comment", "MySQL executable comments are not allowed", n.ref("comment"))
+		case TokenSemicolon:
+			a.block("multiple_statements", "statement separator inside parentheses", n.ref("token"))
+		case TokenWord, TokenQuotedIdent:
+			next := nodeAt(nodes, i+1)
+			name := strings.ToLower(strings.Trim(n.Token.Value, "\"`[]"))
+			if next != nil && next.Group && dangerousFunctions[name] {
+				a.block("dangerous_function", fmt.Sprintf("function %s is not allowed", name), n.ref("function_call"))
+			}
+			if next != nil && next.Group && writeFunctions[name] {
+				a.write("write_function", fmt.Sprintf("function %s changes database state", name), n.ref("function_call"))
+			}
+		}
+	}
+}
+
+func nodeAt(nodes []*Node, i int) *Node {
+	if i >= 0 && i < len(nodes) {
+		return nodes[i]
+	}
+	return nil
+}
+
+// findWord returns the first top-level node equal to kw.
+func findWord(nodes []*Node, kw string) *Node {
+	for _, n := range nodes {
+		if n.isWord(kw) {
+			return n
+		}
+	}
+	return nil
+}
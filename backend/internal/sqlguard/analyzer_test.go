@@ -0,0 +1,94 @@
+package sqlguard
+
+import "testing"
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		query    string
+		allowed  bool
+		readOnly bool
+		rule     string // rule of the first finding, if any
+	}{
+		{"plain select", "postgres", "SELECT id, name FROM students WHERE grade > 80", true, true, ""},
+		{"trailing semicolon", "postgres", "SELECT 1;", true, true, ""},
+		{"keyword in identifier", "postgres", "SELECT create_table_flag, drop_count FROM t", true, true, ""},
+		{"keyword in string", "postgres", "SELECT * FROM t WHERE note = 'DROP TABLE x; DELETE'", true, true, ""},
+		{"keyword in comment", "postgres", "SELECT 1 -- DROP TABLE x\n", true, true, ""},
+		{"read-only cte", "postgres", "WITH x AS (SELECT 1 AS a) SELECT a FROM x", true, true, ""},
+		{"data-modifying cte", "postgres", "WITH x AS (DELETE FROM t WHERE id = 1 RETURNING *) SELECT * FROM x", true, false, "data_modifying_cte"},
+		{"multiple statements", "postgres", "SELECT 1; DROP TABLE t", false, false, "multiple_statements"},
+		{"delete with where", "postgres", "DELETE FROM t WHERE id = 1", true, false, "data_modification"},
+		{"delete without where", "postgres", "DELETE FROM t", false, false, "data_modification"},
+		{"update without where", "mysql", "UPDATE t SET a = 1", false, false, "data_modification"},
+		{"ddl", "postgres", "DROP TABLE t", false, false, "ddl"},
+		{"analyze", "postgres", "ANALYZE DELETE FROM t", false, false, "ddl"},
+		{"explain analyze write", "postgres", "EXPLAIN ANALYZE DELETE FROM t WHERE id = 1", true, false, "data_modification"},
+		{"pg_sleep", "postgres", "SELECT pg_sleep(10)", false, false, "dangerous_function"},
+		{"load_file", "mysql", "SELECT LOAD_FILE('/etc/passwd')", false, false, "dangerous_function"},
+		{"attach database", "sqlite3", "ATTACH DATABASE '/tmp/x.db' AS x", false, false, "attach_database"},
+		{"copy to program", "postgres", "COPY t TO PROGRAM 'rm -rf /'", false, false, "copy_program"},
+		{"select into outfile", "mysql", "SELECT * FROM t INTO OUTFILE '/tmp/x'", false, false, "file_write"},
+		{"locking read", "postgres", "SELECT * FROM t FOR UPDATE", true, false, "locking_read"},
+		{"executable comment", "mysql", "SELECT /*! SLEEP(10) */ 1", false, false, "executable_comment"},
+		{"nextval", "postgres", "SELECT nextval('orders_id_seq')", true, false, "write_function"},
+		{"setval", "postgres", "SELECT setval('orders_id_seq', 1)", true, false, "write_function"},
+		{"lo_create", "postgres", "SELECT lo_create(0)", true, false, "write_function"},
+		{"lo_unlink", "postgres", "SELECT lo_unlink(16403)", true, false, "write_function"},
+		{"lo_put", "postgres", "SELECT lo_put(16403, 0, '\\xff')", true, false, "write_function"},
+		{"lo_from_bytea", "postgres", "SELECT lo_from_bytea(0, 'x')", true, false, "write_function"},
+		{"pg_notify", "postgres", "SELECT pg_notify('jobs', 'run')", true, false, "write_function"},
+		{"write function in subquery", "postgres", "SELECT * FROM (SELECT lo_unlink(oid) FROM pg_largeobject_metadata) x", true, false, "write_function"},
+		{"mysql double dash", "mysql", "SELECT 1--1; DROP TABLE x", false, false, "multiple_statements"},
+		{"mysql dash comment", "mysql", "SELECT 1 -- DROP TABLE x\n", true, true, ""},
+		{"postgres double dash", "postgres", "SELECT 1--1; DROP TABLE x", true, true, ""},
+		{"pragma read", "sqlite3", "PRAGMA journal_mode", true, true, ""},
+		{"pragma table_info", "sqlite3", "PRAGMA main.table_info(students)", true, true, ""},
+		{"pragma assignment", "sqlite3", "PRAGMA journal_mode = OFF", true, false, "pragma_assignment"},
+		{"pragma call", "sqlite3", "PRAGMA writable_schema(1)", true, false, "pragma_assignment"},
+		{"pragma call with schema", "sqlite3", "PRAGMA main.journal_mode(OFF)", true, false, "pragma_assignment"},
+		{"pragma action", "sqlite3", "PRAGMA optimize", true, false, "pragma_action"},
+		{"unbalanced", "postgres", "SELECT (1", false, false, "syntax"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Analyze(tt.dialect, tt.query)
+			if v.Allowed != tt.allowed || v.ReadOnly != tt.readOnly {
+				t.Fatalf("Analyze(%q) = allowed %v, read-only %v (%s); want %v, %v",
+					tt.query, v.Allowed, v.ReadOnly, v.Reason, tt.allowed, tt.readOnly)
+			}
+			rule := ""
+			if len(v.Findings) > 0 {
+				rule = v.Findings[0].Rule
+			}
+			if rule != tt.rule {
+				t.Errorf("Analyze(%q) first rule = %q, want %q", tt.query, rule, tt.rule)
+			}
+		})
+	}
+}
+
+func TestSingleStatement(t *testing.T) {
+	tests := []struct {
+		dialect string
+		query   string
+		ok      bool
+	}{
+		{"postgres", "SELECT 1", true},
+		{"postgres", "SELECT 1;", true},
+		{"postgres", "SELECT ';' AS sep -- ; DROP TABLE t", true},
+		{"postgres", "SELECT 1; COMMIT; DROP TABLE t", false},
+		{"postgres", "SELECT (1; DROP TABLE t)", false},
+		{"mysql", "SELECT 1--1; DROP TABLE t", false},
+		{"sqlite3", "SELECT 'unterminated", false},
+	}
+
+	for _, tt := range tests {
+		err := SingleStatement(tt.dialect, tt.query)
+		if (err == nil) != tt.ok {
+			t.Errorf("SingleStatement(%q) = %v, want ok %v", tt.query, err, tt.ok)
+		}
+	}
+}
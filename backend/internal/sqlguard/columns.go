@@ -0,0 +1,195 @@
+package sqlguard
+
+import "strings"
+
+// selectListEnd are the keywords that end a SELECT list.
+var selectListEnd = map[string]bool{
+	"FROM": true, "INTO": true, "WHERE": true, "GROUP": true, "HAVING": true,
+	"WINDOW": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "FETCH": true,
+	"FOR": true, "LOCK": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+}
+
+// sourceEnd are the keywords that end a FROM clause.
+var sourceEnd = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "WINDOW": true, "ORDER": true,
+	"LIMIT": true, "OFFSET": true, "FETCH": true, "FOR": true, "LOCK": true,
+	"UNION": true, "INTERSECT": true, "EXCEPT": true,
+}
+
+// expressionWords end or join expressions, so a trailing word after them is
+// not an alias.
+var expressionWords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "IS": true, "NULL": true, "TRUE": true,
+	"FALSE": true, "END": true, "THEN": true, "ELSE": true, "LIKE": true,
+	"ILIKE": true, "IN": true, "BETWEEN": true, "COLLATE": true, "DISTINCT": true,
+}
+
+// DistinctColumnNames reports whether the result columns of query, a single
+// SELECT or WITH ... SELECT, are known to have distinct names. It is false
+// when a name cannot be told from the text, such as * over a join. Names
+// are compared case-insensitively.
+func DistinctColumnNames(dialect, query string) bool {
+	tokens, err := Tokenize(dialect, query)
+	if err != nil {
+		return false
+	}
+	nodes, err := buildTree(tokens)
+	if err != nil {
+		return false
+	}
+	statements := splitStatements(nodes)
+	if len(statements) != 1 {
+		return false
+	}
+	return distinctColumns(statements[0])
+}
+
+func distinctColumns(nodes []*Node) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	if first := nodes[0]; first.Group {
+		// (SELECT ...) UNION ...: the first query names the columns
+		return distinctColumns(first.Children)
+	}
+	if nodes[0].isWord("WITH") {
+		i := mainStatement(nodes)
+		if i < 0 {
+			return false
+		}
+		return distinctColumns(nodes[i:])
+	}
+	if !nodes[0].isWord("SELECT") {
+		return false
+	}
+
+	i := 1
+	if n := nodeAt(nodes, i); n != nil && (n.isWord("DISTINCT") || n.isWord("ALL")) {
+		i++
+		if n := nodeAt(nodes, i); n != nil && n.isWord("ON") {
+			i += 2 // DISTINCT ON (...)
+		}
+	}
+
+	var items [][]*Node
+	var current []*Node
+	for ; i < len(nodes); i++ {
+		n := nodes[i]
+		if !n.Group && n.Token.Kind == TokenWord && selectListEnd[n.Token.Upper()] {
+			break
+		}
+		if !n.Group && n.Token.Kind == TokenComma {
+			items = append(items, current)
+			current = nil
+			continue
+		}
+		current = append(current, n)
+	}
+	items = append(items, current)
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if len(item) == 0 {
+			return false
+		}
+		if last := item[len(item)-1]; !last.Group && last.Token.Kind == TokenOperator && last.Token.Value == "*" {
+			// The table's own columns are distinct; anything beside them may not be
+			return len(items) == 1 && singleSource(nodes[i:])
+		}
+		name := columnName(item)
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+	return true
+}
+
+// mainStatement returns the index of the statement following the CTEs of a
+// WITH clause, or -1.
+func mainStatement(nodes []*Node) int {
+	for i := 1; i < len(nodes); i++ {
+		if n := nodes[i]; !n.Group && statementKeywords[n.Token.Upper()] {
+			return i
+		}
+	}
+	return -1
+}
+
+// singleSource reports whether a FROM clause reads exactly one table, or
+// one subquery with distinct column names.
+func singleSource(nodes []*Node) bool {
+	if len(nodes) < 2 || !nodes[0].isWord("FROM") {
+		return false
+	}
+	var source []*Node
+	for _, n := range nodes[1:] {
+		if !n.Group && n.Token.Kind == TokenWord && sourceEnd[n.Token.Upper()] {
+			break
+		}
+		if !n.Group && (n.Token.Kind == TokenComma || n.Token.IsWord("JOIN")) {
+			return false
+		}
+		source = append(source, n)
+	}
+	if len(source) == 0 {
+		return false
+	}
+	if source[0].Group {
+		return distinctColumns(source[0].Children)
+	}
+	return true
+}
+
+// columnName is the name a select list item gets: its alias, the column it
+// refers to, or otherwise its text.
+func columnName(item []*Node) string {
+	last := item[len(item)-1]
+	if len(item) >= 2 && item[len(item)-2].isWord("AS") && !last.Group && last.Token.Kind == TokenString {
+		return identName(last.Token) // AS 'alias'
+	}
+	isIdent := !last.Group && (last.Token.Kind == TokenQuotedIdent ||
+		last.Token.Kind == TokenWord && !expressionWords[last.Token.Upper()])
+
+	if isIdent && len(item) >= 2 {
+		prev := item[len(item)-2]
+		switch {
+		case prev.isWord("AS"), !prev.Group && prev.Token.Kind == TokenDot:
+			return identName(last.Token) // alias or table.column
+		case !prev.Group && prev.Token.Kind == TokenOperator:
+			// an expression such as a + b
+		case prev.Group || prev.Token.Kind != TokenWord || !expressionWords[prev.Token.Upper()]:
+			return identName(last.Token) // implicit alias
+		}
+	}
+	if isIdent && len(item) == 1 {
+		return identName(last.Token)
+	}
+
+	var sb strings.Builder
+	writeNodes(&sb, item)
+	return strings.ToLower(sb.String())
+}
+
+func identName(t Token) string {
+	name := t.Value
+	if t.Kind != TokenWord && len(name) >= 2 {
+		name = name[1 : len(name)-1]
+	}
+	return strings.ToLower(name)
+}
+
+func writeNodes(sb *strings.Builder, nodes []*Node) {
+	for i, n := range nodes {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if n.Group {
+			sb.WriteByte('(')
+			writeNodes(sb, n.Children)
+			sb.WriteByte(')')
+			continue
+		}
+		sb.WriteString(n.Token.Value)
+	}
+}
@@ -0,0 +1,47 @@
+package sqlguard
+
+import "testing"
+
+func TestDistinctColumnNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		query    string
+		distinct bool
+	}{
+		{"plain columns", "postgres", "SELECT id, name FROM students", true},
+		{"same column of two tables", "postgres", "SELECT t.id, u.id FROM t JOIN u ON u.t_id = t.id", false},
+		{"aliased apart", "postgres", "SELECT t.id, u.id AS user_id FROM t JOIN u ON u.t_id = t.id", true},
+		{"case-insensitive", "postgres", "SELECT id, ID FROM t", false},
+		{"quoted identifier", "postgres", `SELECT "id", t.id FROM t`, false},
+		{"implicit alias", "postgres", "SELECT a.name n, b.name FROM a, b", true},
+		{"implicit alias clash", "postgres", "SELECT a.total name, b.name FROM a, b", false},
+		{"star over one table", "postgres", "SELECT * FROM t WHERE id > 1", true},
+		{"star over a join", "postgres", "SELECT * FROM t JOIN u ON u.t_id = t.id", false},
+		{"star over a comma join", "postgres", "SELECT * FROM t, u", false},
+		{"star beside a column", "postgres", "SELECT *, id FROM t", false},
+		{"table star", "postgres", "SELECT t.* FROM t", true},
+		{"star over a subquery", "postgres", "SELECT * FROM (SELECT id, name FROM t) s", true},
+		{"star over a subquery with repeats", "postgres", "SELECT * FROM (SELECT t.id, u.id FROM t JOIN u ON u.t_id = t.id) s", false},
+		{"distinct on", "postgres", "SELECT DISTINCT ON (customer_id) customer_id, id FROM orders ORDER BY customer_id, id", true},
+		{"distinct on with repeats", "postgres", "SELECT DISTINCT ON (o.id) o.id, c.id FROM orders o JOIN customers c ON c.id = o.customer_id", false},
+		{"string alias", "mysql", "SELECT COUNT(*) AS 'total', SUM(x) AS 'total' FROM t", false},
+		{"string aliases apart", "mysql", "SELECT COUNT(*) AS 'n', SUM(x) AS 'total' FROM t", true},
+		{"repeated expression", "postgres", "SELECT COUNT(*), COUNT(*) FROM t", false},
+		{"different expressions", "postgres", "SELECT COUNT(*), COUNT(id) FROM t", true},
+		{"arithmetic is not an alias", "postgres", "SELECT a + b, a + b FROM t", false},
+		{"with select", "postgres", "WITH x AS (SELECT 1 AS a) SELECT a, a FROM x", false},
+		{"with select distinct", "postgres", "WITH x AS (SELECT 1 AS a, 2 AS a) SELECT a FROM x", true},
+		{"parenthesised union", "postgres", "(SELECT id FROM t) UNION (SELECT id FROM u)", true},
+		{"not a select", "postgres", "SHOW tables", false},
+		{"two statements", "postgres", "SELECT 1 AS a; SELECT 2 AS b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DistinctColumnNames(tt.dialect, tt.query); got != tt.distinct {
+				t.Errorf("DistinctColumnNames(%q) = %v, want %v", tt.query, got, tt.distinct)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package sqlguard
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenKind int
+
+const (
+	TokenWord TokenKind = iota // keywords and bare identifiers
+	TokenQuotedIdent
+	TokenString
+	TokenNumber
+	TokenParam
+	TokenOperator
+	TokenLParen
+	TokenRParen
+	TokenComma
+	TokenDot
+	TokenSemicolon
+	TokenExecComment // MySQL /*! ... */ comments, which the server executes
+)
+
+// SyntaxError reports input the tokenizer or parser could not make sense of.
+type SyntaxError struct {
+	Msg string
+	Pos int
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+type Token struct {
+	Kind  TokenKind
+	Value string
+	Pos   int // byte offset in the original query
+}
+
+// Upper returns the token value upper-cased, for keyword comparison.
+func (t Token) Upper() string {
+	return strings.ToUpper(t.Value)
+}
+
+// IsWord reports whether t is a bare word equal to kw (case-insensitive).
+func (t Token) IsWord(kw string) bool {
+	return t.Kind == TokenWord && strings.EqualFold(t.Value, kw)
+}
+
+// Tokenize splits query into tokens using the lexical rules of dialect
+// (postgres, mysql or sqlite3). Comments are dropped, except MySQL
+// executable comments which are kept as TokenExecComment.
+func Tokenize(dialect, query string) ([]Token, error) {
+	l := &lexer{dialect: dialect, src: query}
+	return l.run()
+}
+
+type lexer struct {
+	dialect string
+	src     string
+	pos     int
+	tokens  []Token
+}
+
+func (l *lexer) run() ([]Token, error) {
+	for l.pos < len(l.src) {
+		start := l.pos
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+
+		switch {
+		case unicode.IsSpace(r):
+			l.pos += size
+
+		case l.dashComment():
+			l.skipLine()
+
+		case r == '#' && l.dialect == "mysql":
+			l.skipLine()
+
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			if err := l.blockComment(); err != nil {
+				return nil, err
+			}
+
+		case r == '\'':
+			if err := l.quoted('\'', TokenString, l.dialect == "mysql"); err != nil {
+				return nil, err
+			}
+
+		case r == '"':
+			// MySQL treats double quotes as strings unless ANSI_QUOTES is set
+			if l.dialect == "mysql" {
+				if err := l.quoted('"', TokenString, true); err != nil {
+					return nil, err
+				}
+			} else if err := l.quoted('"', TokenQuotedIdent, false); err != nil {
+				return nil, err
+			}
+
+		case r == '`' && l.dialect != "postgres":
+			if err := l.quoted('`', TokenQuotedIdent, false); err != nil {
+				return nil, err
+			}
+
+		case r == '[' && l.dialect == "sqlite3":
+			end := strings.IndexByte(l.src[l.pos+1:], ']')
+			if end < 0 {
+				return nil, &SyntaxError{Msg: "unterminated quoted identifier", Pos: start}
+			}
+			l.pos += end + 2
+			l.emit(TokenQuotedIdent, start)
+
+		case r == '$' && l.dialect == "postgres":
+			if err := l.dollar(); err != nil {
+				return nil, err
+			}
+
+		case (r == 'E' || r == 'e') && l.dialect == "postgres" && l.peekByte(1) == '\'':
+			l.pos++
+			if err := l.quoted('\'', TokenString, true); err != nil {
+				return nil, err
+			}
+			l.tokens[len(l.tokens)-1].Pos = start
+			l.tokens[len(l.tokens)-1].Value = l.src[start:l.pos]
+
+		case r == '?':
+			l.pos++
+			for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+				l.pos++
+			}
+			l.emit(TokenParam, start)
+
+		case (r == ':' || r == '@') && l.dialect != "postgres" && isWordStart(l.peekRune(1)):
+			l.pos++
+			if r == '@' && l.peekByte(0) == '@' {
+				l.pos++
+			}
+			l.word()
+			l.emit(TokenParam, start)
+
+		case (r >= '0' && r <= '9') || (r == '.' && isDigit(l.peekByte(1))):
+			l.number()
+			l.emit(TokenNumber, start)
+
+		case isWordStart(r):
+			l.word()
+			l.emit(TokenWord, start)
+
+		case r == '(':
+			l.pos++
+			l.emit(TokenLParen, start)
+		case r == ')':
+			l.pos++
+			l.emit(TokenRParen, start)
+		case r == ',':
+			l.pos++
+			l.emit(TokenComma, start)
+		case r == '.':
+			l.pos++
+			l.emit(TokenDot, start)
+		case r == ';':
+			l.pos++
+			l.emit(TokenSemicolon, start)
+
+		default:
+			l.operator()
+			l.emit(TokenOperator, start)
+		}
+	}
+
+	return l.tokens, nil
+}
+
+func (l *lexer) emit(kind TokenKind, start int) {
+	l.tokens = append(l.tokens, Token{Kind: kind, Value: l.src[start:l.pos], Pos: start})
+}
+
+func (l *lexer) peekByte(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *lexer) peekRune(offset int) rune {
+	if l.pos+offset < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos+offset:])
+		return r
+	}
+	return 0
+}
+
+// dashComment reports whether a -- comment starts at the current position.
+// MySQL only takes -- as a comment when whitespace or a control character
+// follows, so 1--1 is 1 - (-1) there.
+func (l *lexer) dashComment() bool {
+	if !strings.HasPrefix(l.src[l.pos:], "--") {
+		return false
+	}
+	if l.dialect != "mysql" || l.pos+2 == len(l.src) {
+		return true
+	}
+	c := l.src[l.pos+2]
+	return c <= ' ' || c == 0x7f
+}
+
+func (l *lexer) skipLine() {
+	end := strings.IndexByte(l.src[l.pos:], '\n')
+	if end < 0 {
+		l.pos = len(l.src)
+		return
+	}
+	l.pos += end + 1
+}
+
+// blockComment skips /* ... */. Postgres allows nesting; MySQL /*! ... */
+// comments are executed by the server and are surfaced as a token.
+func (l *lexer) blockComment() error {
+	start := l.pos
+	executable := l.dialect == "mysql" && strings.HasPrefix(l.src[l.pos:], "/*!")
+	depth := 0
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			if depth == 0 || l.dialect == "postgres" {
+				depth++
+			}
+			l.pos += 2
+		case strings.HasPrefix(l.src[l.pos:], "*/"):
+			depth--
+			l.pos += 2
+			if depth == 0 {
+				if executable {
+					l.emit(TokenExecComment, start)
+				}
+				return nil
+			}
+		default:
+			l.pos++
+		}
+	}
+	return &SyntaxError{Msg: "unterminated comment", Pos: start}
+}
+
+// quoted consumes a quoted literal, honouring doubled quotes and, when
+// backslash is set, backslash escapes.
+func (l *lexer) quoted(quote byte, kind TokenKind, backslash bool) error {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case backslash && c == '\\':
+			l.pos += 2
+		case c == quote && l.peekByte(1) == quote:
+			l.pos += 2
+		case c == quote:
+			l.pos++
+			l.emit(kind, start)
+			return nil
+		default:
+			l.pos++
+		}
+	}
+	return &SyntaxError{Msg: "unterminated quoted text", Pos: start}
+}
+
+// dollar handles Postgres positional parameters ($1) and dollar-quoted
+// strings ($$...$$, $tag$...$tag$).
+func (l *lexer) dollar() error {
+	start := l.pos
+	if isDigit(l.peekByte(1)) {
+		l.pos++
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		l.emit(TokenParam, start)
+		return nil
+	}
+
+	end := strings.IndexByte(l.src[l.pos+1:], '$')
+	if end < 0 {
+		l.pos++
+		l.emit(TokenOperator, start)
+		return nil
+	}
+	tag := l.src[l.pos : l.pos+end+2]
+	for _, r := range tag[1 : len(tag)-1] {
+		if !isWordPart(r) {
+			l.pos++
+			l.emit(TokenOperator, start)
+			return nil
+		}
+	}
+
+	body := l.pos + len(tag)
+	closeAt := strings.Index(l.src[body:], tag)
+	if closeAt < 0 {
+		return &SyntaxError{Msg: "unterminated dollar-quoted string", Pos: start}
+	}
+	l.pos = body + closeAt + len(tag)
+	l.emit(TokenString, start)
+	return nil
+}
+
+func (l *lexer) word() {
+	for l.pos < len(l.src) {
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		if !isWordPart(r) {
+			return
+		}
+		l.pos += size
+	}
+}
+
+func (l *lexer) number() {
+	if l.peekByte(0) == '0' && (l.peekByte(1) == 'x' || l.peekByte(1) == 'X') {
+		l.pos += 2
+		for l.pos < len(l.src) && isHexDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		return
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case isDigit(c) || c == '.' || c == '_':
+			l.pos++
+		case c == 'e' || c == 'E':
+			l.pos++
+			if l.peekByte(0) == '+' || l.peekByte(0) == '-' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) operator() {
+	const ops = "+-*/<>=~!@#%^&|`?:"
+	_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.pos += size
+	for l.pos < len(l.src) && strings.IndexByte(ops, l.src[l.pos]) >= 0 &&
+		!l.dashComment() && !strings.HasPrefix(l.src[l.pos:], "/*") {
+		l.pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isWordPart(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gibranda/chat-with-database/internal/database"
+)
+
+// ListDescriptions returns the description overrides stored for a
+// connection, as identified by the caller (a profile name or the database).
+func (s *Store) ListDescriptions(ctx context.Context, connection string) ([]database.DescriptionOverride, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT schema_name, table_name, column_name, description
+		FROM schema_descriptions
+		WHERE connection = ?
+		ORDER BY schema_name, table_name, column_name`, connection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list descriptions: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]database.DescriptionOverride, 0)
+	for rows.Next() {
+		var o database.DescriptionOverride
+		if err := rows.Scan(&o.Schema, &o.Table, &o.Column, &o.Description); err != nil {
+			return nil, err
+		}
+		list = append(list, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read descriptions: %w", err)
+	}
+	return list, nil
+}
+
+// SetDescription stores an override for a connection, replacing any
+// previous one. An empty description removes the override.
+func (s *Store) SetDescription(ctx context.Context, connection string, o database.DescriptionOverride) error {
+	o.Description = strings.TrimSpace(o.Description)
+	if o.Description == "" {
+		_, err := s.db.ExecContext(ctx, `
+			DELETE FROM schema_descriptions
+			WHERE connection = ? AND schema_name = ? AND table_name = ? AND column_name = ?`,
+			connection, o.Schema, o.Table, o.Column)
+		if err != nil {
+			return fmt.Errorf("failed to delete description: %w", err)
+		}
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schema_descriptions (connection, schema_name, table_name, column_name, description, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (connection, schema_name, table_name, column_name)
+		DO UPDATE SET description = excluded.description, updated_at = excluded.updated_at`,
+		connection, o.Schema, o.Table, o.Column, o.Description, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save description: %w", err)
+	}
+	return nil
+}
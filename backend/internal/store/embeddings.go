@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// maxEmbeddingLookup caps the keys looked up in one statement.
+const maxEmbeddingLookup = 500
+
+// GetEmbeddings returns the stored vectors for keys (hashes of the embedded
+// text) computed with model. Keys without a vector are left out.
+func (s *Store) GetEmbeddings(ctx context.Context, model string, keys []string) (map[string][]float32, error) {
+	vectors := make(map[string][]float32, len(keys))
+	for start := 0; start < len(keys); start += maxEmbeddingLookup {
+		batch := keys[start:min(start+maxEmbeddingLookup, len(keys))]
+
+		args := make([]interface{}, 0, len(batch)+1)
+		args = append(args, model)
+		for _, key := range batch {
+			args = append(args, key)
+		}
+		rows, err := s.db.QueryContext(ctx,
+			"SELECT text_hash, vector FROM embeddings WHERE model = ? AND text_hash IN (?"+strings.Repeat(", ?", len(batch)-1)+")",
+			args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embeddings: %w", err)
+		}
+
+		for rows.Next() {
+			var key string
+			var blob []byte
+			if err := rows.Scan(&key, &blob); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			vectors[key] = decodeVector(blob)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embeddings: %w", err)
+		}
+	}
+	return vectors, nil
+}
+
+// PutEmbeddings stores vectors computed with model, keyed by a hash of the
+// embedded text.
+func (s *Store) PutEmbeddings(ctx context.Context, model string, vectors map[string][]float32) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save embeddings: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for key, vector := range vectors {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR REPLACE INTO embeddings (model, text_hash, vector, created_at)
+			VALUES (?, ?, ?, ?)`,
+			model, key, encodeVector(vector), now)
+		if err != nil {
+			return fmt.Errorf("failed to save embeddings: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// encodeVector packs a vector as little-endian float32s.
+func encodeVector(vector []float32) []byte {
+	b := make([]byte, 4*len(vector))
+	for i, f := range vector {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	vector := make([]float32, len(b)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return vector
+}
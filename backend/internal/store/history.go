@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SourceAgent      = "agent"
+	SourceSavedQuery = "saved_query"
+
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// HistoryEntry is one question (or saved query run) and its outcome.
+type HistoryEntry struct {
+	ID         int64           `json:"id"`
+	Source     string          `json:"source"` // agent, saved_query
+	Profile    string          `json:"profile,omitempty"`
+	DBType     string          `json:"db_type,omitempty"`
+	DBName     string          `json:"db_name,omitempty"`
+	Question   string          `json:"question"`
+	SQL        string          `json:"sql,omitempty"`
+	Answer     string          `json:"answer,omitempty"`
+	Reasoning  json.RawMessage `json:"reasoning,omitempty"`
+	RowCount   int             `json:"row_count"`
+	DurationMS int64           `json:"duration_ms"`
+	Success    bool            `json:"success"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// HistoryFilter narrows ListHistory. Zero values match everything.
+type HistoryFilter struct {
+	Search  string // matched against question, SQL and answer
+	Profile string
+	Success *bool
+	Limit   int
+	Offset  int
+}
+
+// AddHistory records entry, filling in its ID and creation time.
+func (s *Store) AddHistory(ctx context.Context, entry *HistoryEntry) error {
+	if entry.Source == "" {
+		entry.Source = SourceAgent
+	}
+	if len(entry.Reasoning) == 0 {
+		entry.Reasoning = json.RawMessage("[]")
+	}
+	entry.CreatedAt = time.Now().UTC()
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO query_history
+			(source, profile, db_type, db_name, question, sql, answer, reasoning,
+			 row_count, duration_ms, success, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Source, entry.Profile, entry.DBType, entry.DBName, entry.Question, entry.SQL,
+		entry.Answer, string(entry.Reasoning), entry.RowCount, entry.DurationMS,
+		entry.Success, entry.Error, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+
+	entry.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// ListHistory returns matching entries, newest first, without their
+// reasoning, along with the total number of matches.
+func (s *Store) ListHistory(ctx context.Context, filter HistoryFilter) ([]HistoryEntry, int, error) {
+	var where []string
+	var args []interface{}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		where = append(where, `(question LIKE ? ESCAPE '\' OR sql LIKE ? ESCAPE '\' OR answer LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if filter.Profile != "" {
+		where = append(where, "profile = ?")
+		args = append(args, filter.Profile)
+	}
+	if filter.Success != nil {
+		where = append(where, "success = ?")
+		args = append(args, *filter.Success)
+	}
+
+	clause := ""
+	if len(where) > 0 {
+		clause = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM query_history "+clause, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count history: %w", err)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, source, profile, db_type, db_name, question, sql, answer,
+		       row_count, duration_ms, success, error, created_at
+		FROM query_history `+clause+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		var e HistoryEntry
+		if err := rows.Scan(&e.ID, &e.Source, &e.Profile, &e.DBType, &e.DBName, &e.Question, &e.SQL, &e.Answer,
+			&e.RowCount, &e.DurationMS, &e.Success, &e.Error, &e.CreatedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to read history: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return entries, total, nil
+}
+
+// GetHistory returns one entry including its reasoning.
+func (s *Store) GetHistory(ctx context.Context, id int64) (*HistoryEntry, error) {
+	var e HistoryEntry
+	var reasoning string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, source, profile, db_type, db_name, question, sql, answer, reasoning,
+		       row_count, duration_ms, success, error, created_at
+		FROM query_history WHERE id = ?`, id).
+		Scan(&e.ID, &e.Source, &e.Profile, &e.DBType, &e.DBName, &e.Question, &e.SQL, &e.Answer, &reasoning,
+			&e.RowCount, &e.DurationMS, &e.Success, &e.Error, &e.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history entry: %w", err)
+	}
+
+	e.Reasoning = json.RawMessage(reasoning)
+	return &e, nil
+}
+
+func (s *Store) DeleteHistory(ctx context.Context, id int64) error {
+	return s.deleteByID(ctx, "query_history", id)
+}
+
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(s)
+}
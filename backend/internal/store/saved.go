@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SavedQuery is a named SQL statement that can be re-run without the LLM.
+type SavedQuery struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Question    string     `json:"question,omitempty"`
+	SQL         string     `json:"sql"`
+	Profile     string     `json:"profile,omitempty"`
+	Pinned      bool       `json:"pinned"`
+	RunCount    int        `json:"run_count"`
+	LastRunAt   *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Validate checks the fields a saved query needs.
+func (q *SavedQuery) Validate() error {
+	q.Name = strings.TrimSpace(q.Name)
+	q.SQL = strings.TrimSpace(q.SQL)
+	if q.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if q.SQL == "" {
+		return fmt.Errorf("%w: sql is required", ErrInvalid)
+	}
+	return nil
+}
+
+const savedQueryColumns = `id, name, description, question, sql, profile, pinned, run_count, last_run_at, created_at, updated_at`
+
+// ListSavedQueries returns saved queries, pinned first and then by name.
+func (s *Store) ListSavedQueries(ctx context.Context, profile string) ([]SavedQuery, error) {
+	query := "SELECT " + savedQueryColumns + " FROM saved_queries"
+	var args []interface{}
+	if profile != "" {
+		query += " WHERE profile = ?"
+		args = append(args, profile)
+	}
+	query += " ORDER BY pinned DESC, name COLLATE NOCASE"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved queries: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]SavedQuery, 0)
+	for rows.Next() {
+		q, err := scanSavedQuery(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read saved queries: %w", err)
+	}
+	return list, nil
+}
+
+func (s *Store) GetSavedQuery(ctx context.Context, id int64) (*SavedQuery, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+savedQueryColumns+" FROM saved_queries WHERE id = ?", id)
+	q, err := scanSavedQuery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return q, err
+}
+
+// CreateSavedQuery stores q, filling in its ID and timestamps.
+func (s *Store) CreateSavedQuery(ctx context.Context, q *SavedQuery) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	q.CreatedAt, q.UpdatedAt = now, now
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO saved_queries (name, description, question, sql, profile, pinned, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		q.Name, q.Description, q.Question, q.SQL, q.Profile, q.Pinned, q.CreatedAt, q.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save query: %w", err)
+	}
+
+	q.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// UpdateSavedQuery replaces the editable fields of the saved query q.ID.
+func (s *Store) UpdateSavedQuery(ctx context.Context, q *SavedQuery) error {
+	if err := q.Validate(); err != nil {
+		return err
+	}
+	q.UpdatedAt = time.Now().UTC()
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE saved_queries
+		SET name = ?, description = ?, question = ?, sql = ?, profile = ?, pinned = ?, updated_at = ?
+		WHERE id = ?`,
+		q.Name, q.Description, q.Question, q.SQL, q.Profile, q.Pinned, q.UpdatedAt, q.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update saved query: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkSavedQueryRun bumps the run counter and last-run time.
+func (s *Store) MarkSavedQueryRun(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE saved_queries SET run_count = run_count + 1, last_run_at = ? WHERE id = ?",
+		time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update saved query: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) DeleteSavedQuery(ctx context.Context, id int64) error {
+	return s.deleteByID(ctx, "saved_queries", id)
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSavedQuery(row rowScanner) (*SavedQuery, error) {
+	var q SavedQuery
+	var lastRun sql.NullTime
+	err := row.Scan(&q.ID, &q.Name, &q.Description, &q.Question, &q.SQL, &q.Profile, &q.Pinned,
+		&q.RunCount, &lastRun, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read saved query: %w", err)
+	}
+	if lastRun.Valid {
+		q.LastRunAt = &lastRun.Time
+	}
+	return &q, nil
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrInvalid  = errors.New("invalid saved query")
+)
+
+// Store persists query history, saved queries, schema description
+// overrides and schema embeddings in an embedded SQLite file, independent
+// of the databases users connect to.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS query_history (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	source      TEXT    NOT NULL DEFAULT 'agent',
+	profile     TEXT    NOT NULL DEFAULT '',
+	db_type     TEXT    NOT NULL DEFAULT '',
+	db_name     TEXT    NOT NULL DEFAULT '',
+	question    TEXT    NOT NULL,
+	sql         TEXT    NOT NULL DEFAULT '',
+	answer      TEXT    NOT NULL DEFAULT '',
+	reasoning   TEXT    NOT NULL DEFAULT '[]',
+	row_count   INTEGER NOT NULL DEFAULT 0,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	success     INTEGER NOT NULL DEFAULT 0,
+	error       TEXT    NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_query_history_created ON query_history (created_at);
+
+CREATE TABLE IF NOT EXISTS saved_queries (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	name        TEXT    NOT NULL,
+	description TEXT    NOT NULL DEFAULT '',
+	question    TEXT    NOT NULL DEFAULT '',
+	sql         TEXT    NOT NULL,
+	profile     TEXT    NOT NULL DEFAULT '',
+	pinned      INTEGER NOT NULL DEFAULT 0,
+	run_count   INTEGER NOT NULL DEFAULT 0,
+	last_run_at TIMESTAMP,
+	created_at  TIMESTAMP NOT NULL,
+	updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS schema_descriptions (
+	connection  TEXT NOT NULL,
+	schema_name TEXT NOT NULL DEFAULT '',
+	table_name  TEXT NOT NULL,
+	column_name TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL,
+	updated_at  TIMESTAMP NOT NULL,
+	PRIMARY KEY (connection, schema_name, table_name, column_name)
+);
+
+CREATE TABLE IF NOT EXISTS embeddings (
+	model      TEXT NOT NULL,
+	text_hash  TEXT NOT NULL,
+	vector     BLOB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (model, text_hash)
+);
+`
+
+// Open opens (creating if needed) the store at path.
+func Open(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+	// SQLite allows a single writer; one connection avoids busy errors
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// deleteByID removes a row from table, reporting ErrNotFound when absent.
+func (s *Store) deleteByID(ctx context.Context, table string, id int64) error {
+	res, err := s.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = ?", table), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -2,20 +2,50 @@ export const useApi = () => {
   const config = useRuntimeConfig()
   const apiBase = config.public.apiBase
 
+  // The backend scopes connections and conversation history to a session,
+  // identified by the X-Session-ID header it hands out on first contact.
+  const sessionKey = 'db-agent-session'
+
+  const sessionHeaders = (): Record<string, string> => {
+    if (typeof window === 'undefined') return {}
+    const id = sessionStorage.getItem(sessionKey)
+    return id ? { 'X-Session-ID': id } : {}
+  }
+
+  const rememberSession = (headers: Headers) => {
+    const id = headers.get('X-Session-ID')
+    if (id && typeof window !== 'undefined') {
+      sessionStorage.setItem(sessionKey, id)
+    }
+  }
+
+  const request = (path: string, options: any = {}) => {
+    return $fetch(`${apiBase}${path}`, {
+      ...options,
+      headers: { ...sessionHeaders(), ...(options.headers || {}) },
+      onResponse({ response }: any) {
+        rememberSession(response.headers)
+      }
+    })
+  }
+
   const checkHealth = async () => {
     try {
-      const response = await $fetch(`${apiBase}/health`)
+      const response = await request(`/health`)
       return { success: true, data: response }
     } catch (error) {
       return { success: false, error }
     }
   }
 
-  const sendQuery = async (question: string) => {
+  // id is optional; passing one lets the caller cancel the run with cancelQuery.
+  // language (e.g. 'en') overrides the server's answer language for this question.
+  // clarificationId sends the question as the reply to that clarification.
+  const sendQuery = async (question: string, id?: string, language?: string, clarificationId?: string) => {
     try {
-      const response = await $fetch(`${apiBase}/query`, {
+      const response = await request(`/query`, {
         method: 'POST',
-        body: { question }
+        body: { question, id, language, clarification_id: clarificationId }
       })
       return { success: true, data: response }
     } catch (error: any) {
@@ -26,9 +56,77 @@ export const useApi = () => {
     }
   }
 
+  // Streams a query over Server-Sent Events. onEvent receives every
+  // start/step/token/result/error event as it arrives; the start event
+  // carries the query_id accepted by cancelQuery.
+  const streamQuery = async (question: string, onEvent: (type: string, data: any) => void, id?: string, language?: string, clarificationId?: string) => {
+    try {
+      const response = await fetch(`${apiBase}/query/stream`, {
+        method: 'POST',
+        headers: { ...sessionHeaders(), 'Content-Type': 'application/json', Accept: 'text/event-stream' },
+        body: JSON.stringify({ question, id, language, clarification_id: clarificationId })
+      })
+      rememberSession(response.headers)
+      if (!response.ok || !response.body) {
+        const body = await response.json().catch(() => ({}))
+        return { success: false, error: body.error || `Request failed with status ${response.status}` }
+      }
+
+      const reader = response.body.getReader()
+      const decoder = new TextDecoder()
+      let buffer = ''
+      let result: any = null
+
+      while (true) {
+        const { done, value } = await reader.read()
+        if (done) break
+        buffer += decoder.decode(value, { stream: true })
+
+        let boundary
+        while ((boundary = buffer.indexOf('\n\n')) !== -1) {
+          const chunk = buffer.slice(0, boundary)
+          buffer = buffer.slice(boundary + 2)
+
+          let type = 'message'
+          const dataLines: string[] = []
+          for (const line of chunk.split('\n')) {
+            if (line.startsWith('event:')) type = line.slice(6).trim()
+            else if (line.startsWith('data:')) dataLines.push(line.slice(5).trim())
+          }
+          if (dataLines.length === 0) continue
+
+          const data = JSON.parse(dataLines.join('\n'))
+          if (type === 'result') result = data.response
+          onEvent(type, data)
+        }
+      }
+
+      return { success: true, data: result }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to stream query'
+      }
+    }
+  }
+
+  const cancelQuery = async (id: string) => {
+    try {
+      const response = await request(`/query/${encodeURIComponent(id)}/cancel`, {
+        method: 'POST'
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to cancel query' 
+      }
+    }
+  }
+
   const getSchema = async () => {
     try {
-      const response = await $fetch(`${apiBase}/schema`)
+      const response = await request(`/schema`)
       return { success: true, data: response }
     } catch (error: any) {
       return { 
@@ -40,7 +138,7 @@ export const useApi = () => {
 
   const refreshSchema = async () => {
     try {
-      const response = await $fetch(`${apiBase}/schema/refresh`, {
+      const response = await request(`/schema/refresh`, {
         method: 'POST'
       })
       return { success: true, data: response }
@@ -52,9 +150,37 @@ export const useApi = () => {
     }
   }
 
+  const listDescriptions = async () => {
+    try {
+      const response = await request(`/schema/descriptions`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to fetch descriptions'
+      }
+    }
+  }
+
+  // An empty description removes the override
+  const saveDescription = async (table: string, description: string, column?: string) => {
+    try {
+      const response = await request(`/schema/descriptions`, {
+        method: 'PUT',
+        body: JSON.stringify({ table, column, description })
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to save description'
+      }
+    }
+  }
+
   const getTables = async () => {
     try {
-      const response = await $fetch(`${apiBase}/tables`)
+      const response = await request(`/tables`)
       return { success: true, data: response }
     } catch (error: any) {
       return { 
@@ -66,7 +192,7 @@ export const useApi = () => {
 
   const getTableInfo = async (tableName: string) => {
     try {
-      const response = await $fetch(`${apiBase}/tables/${tableName}`)
+      const response = await request(`/tables/${tableName}`)
       return { success: true, data: response }
     } catch (error: any) {
       return { 
@@ -76,9 +202,21 @@ export const useApi = () => {
     }
   }
 
+  const getTableProfile = async (tableName: string) => {
+    try {
+      const response = await request(`/tables/${tableName}/profile`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to profile table'
+      }
+    }
+  }
+
   const clearHistory = async () => {
     try {
-      const response = await $fetch(`${apiBase}/history/clear`, {
+      const response = await request(`/history/clear`, {
         method: 'POST'
       })
       return { success: true, data: response }
@@ -92,7 +230,7 @@ export const useApi = () => {
 
   const testConnection = async (credentials: any) => {
     try {
-      const response = await $fetch(`${apiBase}/connection/test`, {
+      const response = await request(`/connection/test`, {
         method: 'POST',
         body: credentials
       })
@@ -107,7 +245,7 @@ export const useApi = () => {
 
   const connect = async (credentials: any) => {
     try {
-      const response = await $fetch(`${apiBase}/connection/connect`, {
+      const response = await request(`/connection/connect`, {
         method: 'POST',
         body: credentials
       })
@@ -122,7 +260,7 @@ export const useApi = () => {
 
   const disconnect = async () => {
     try {
-      const response = await $fetch(`${apiBase}/connection/disconnect`, {
+      const response = await request(`/connection/disconnect`, {
         method: 'POST'
       })
       return { success: true, data: response }
@@ -136,7 +274,7 @@ export const useApi = () => {
 
   const getConnectionStatus = async () => {
     try {
-      const response = await $fetch(`${apiBase}/connection/status`)
+      const response = await request(`/connection/status`)
       return { success: true, data: response }
     } catch (error: any) {
       return { 
@@ -146,17 +284,246 @@ export const useApi = () => {
     }
   }
 
+  // Persistent query history: params may include q, profile, success,
+  // limit and offset
+  const listHistory = async (params: Record<string, any> = {}) => {
+    try {
+      const response = await request(`/history`, { query: params })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to load query history' 
+      }
+    }
+  }
+
+  const getHistoryEntry = async (id: number) => {
+    try {
+      const response = await request(`/history/${id}`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to load history entry' 
+      }
+    }
+  }
+
+  const deleteHistoryEntry = async (id: number) => {
+    try {
+      const response = await request(`/history/${id}`, {
+        method: 'DELETE'
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to delete history entry' 
+      }
+    }
+  }
+
+  const listSavedQueries = async () => {
+    try {
+      const response = await request(`/saved-queries`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to load saved queries' 
+      }
+    }
+  }
+
+  // Creates a saved query, or updates the given fields when it has an id
+  const saveQuery = async (query: any) => {
+    try {
+      const response = await request(query.id ? `/saved-queries/${query.id}` : `/saved-queries`, {
+        method: query.id ? 'PUT' : 'POST',
+        body: query
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to save query' 
+      }
+    }
+  }
+
+  const deleteSavedQuery = async (id: number) => {
+    try {
+      const response = await request(`/saved-queries/${id}`, {
+        method: 'DELETE'
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to delete saved query' 
+      }
+    }
+  }
+
+  // Re-runs a saved query's SQL without the LLM
+  const runSavedQuery = async (id: number) => {
+    try {
+      const response = await request(`/saved-queries/${id}/run`, {
+        method: 'POST'
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to run saved query' 
+      }
+    }
+  }
+
+  // Downloads the full, uncapped result of a history entry or saved query.
+  // format is one of csv, xlsx, ndjson or parquet.
+  const fetchResultPage = async (resultId: string, page: { offset?: number, limit?: number, key?: string[], desc?: boolean, cursor?: string } = {}) => {
+    try {
+      const params = new URLSearchParams()
+      if (page.cursor) {
+        params.set('cursor', page.cursor)
+      } else {
+        if (page.offset) params.set('offset', String(page.offset))
+        if (page.key?.length) params.set('key', page.key.join(','))
+        if (page.desc) params.set('desc', 'true')
+      }
+      if (page.limit) params.set('limit', String(page.limit))
+
+      const response = await request(`/results/${resultId}?${params}`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to load results page'
+      }
+    }
+  }
+
+  const countResult = async (resultId: string) => {
+    try {
+      const response = await request(`/results/${resultId}/count`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to count result rows'
+      }
+    }
+  }
+
+  const exportResults = async (source: { historyId?: number, savedQueryId?: number }, format = 'csv') => {
+    try {
+      const params = new URLSearchParams({ format })
+      if (source.historyId) params.set('history_id', String(source.historyId))
+      if (source.savedQueryId) params.set('saved_query_id', String(source.savedQueryId))
+
+      const response = await fetch(`${apiBase}/export?${params}`, { headers: sessionHeaders() })
+      if (!response.ok) {
+        const body = await response.json().catch(() => ({}))
+        return { success: false, error: body.error || `Export failed with status ${response.status}` }
+      }
+
+      const disposition = response.headers.get('Content-Disposition') || ''
+      const filename = disposition.match(/filename="([^"]+)"/)?.[1] || `export.${format}`
+      const url = URL.createObjectURL(await response.blob())
+      const link = document.createElement('a')
+      link.href = url
+      link.download = filename
+      link.click()
+      URL.revokeObjectURL(url)
+
+      return { success: true, data: { filename } }
+    } catch (error: any) {
+      return {
+        success: false,
+        error: error.message || 'Failed to export results'
+      }
+    }
+  }
+
+  const listProfiles = async () => {
+    try {
+      const response = await request(`/connection/profiles`)
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to load connection profiles' 
+      }
+    }
+  }
+
+  // Creates the profile, or updates it when it already exists
+  const saveProfile = async (profile: any, exists = false) => {
+    try {
+      const response = await request(
+        exists ? `/connection/profiles/${encodeURIComponent(profile.name)}` : `/connection/profiles`,
+        {
+          method: exists ? 'PUT' : 'POST',
+          body: profile
+        }
+      )
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to save connection profile' 
+      }
+    }
+  }
+
+  const deleteProfile = async (name: string) => {
+    try {
+      const response = await request(`/connection/profiles/${encodeURIComponent(name)}`, {
+        method: 'DELETE'
+      })
+      return { success: true, data: response }
+    } catch (error: any) {
+      return { 
+        success: false, 
+        error: error.message || 'Failed to delete connection profile' 
+      }
+    }
+  }
+
+  const connectProfile = (profile: string) => connect({ profile })
+
   return {
     checkHealth,
     sendQuery,
+    streamQuery,
+    cancelQuery,
     getSchema,
     refreshSchema,
+    listDescriptions,
+    saveDescription,
     getTables,
     getTableInfo,
+    getTableProfile,
     clearHistory,
     testConnection,
     connect,
     disconnect,
-    getConnectionStatus
+    getConnectionStatus,
+    listHistory,
+    getHistoryEntry,
+    deleteHistoryEntry,
+    listSavedQueries,
+    saveQuery,
+    deleteSavedQuery,
+    runSavedQuery,
+    fetchResultPage,
+    countResult,
+    exportResults,
+    listProfiles,
+    saveProfile,
+    deleteProfile,
+    connectProfile
   }
 }
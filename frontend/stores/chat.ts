@@ -7,14 +7,46 @@ export interface Message {
   sql?: string
   results?: QueryResult
   reasoning?: ReasoningStep[]
+  // What the backend classified the question as: schema, data, follow_up,
+  // explain_sql, chit_chat or write
+  intent?: string
+  // What the model assumed when writing the SQL, and how sure it was (0-1)
+  assumptions?: string[]
+  confidence?: number
+  // Set when the backend asks back instead of answering; a question sent
+  // with its id is taken as the reply
+  clarification?: Clarification
   timestamp: Date
   error?: string
 }
 
+export interface Clarification {
+  id: string
+  question: string
+  options?: string[]
+}
+
+// kind tells how values are encoded: decimals, timestamps, dates, times,
+// UUIDs and binary (base64) arrive as strings, json columns as parsed values
+export interface ResultColumn {
+  name: string
+  database_type: string
+  kind: 'string' | 'integer' | 'float' | 'decimal' | 'boolean' | 'timestamp' | 'date' | 'time' | 'uuid' | 'json' | 'binary'
+  go_type?: string
+  nullable?: boolean
+  length?: number
+  precision?: number
+  scale?: number
+}
+
 export interface QueryResult {
-  columns: string[]
-  rows: Record<string, any>[]
+  columns: ResultColumn[]
+  // Each row holds one value per column, in column order
+  rows: any[][]
   count: number
+  // Row cap applied by the server and whether the query had more rows
+  limit?: number
+  truncated?: boolean
 }
 
 export interface ReasoningStep {
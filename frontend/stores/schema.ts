@@ -6,22 +6,65 @@ export interface Column {
   nullable: boolean
   primary_key: boolean
   foreign_key?: string
+  default?: string
+  // Column comment, or a description stored locally
+  description?: string
+}
+
+export interface ForeignKey {
+  name?: string
+  columns: string[]
+  ref_table: { schema?: string, name: string }
+  ref_columns: string[]
+}
+
+export interface Index {
+  name: string
+  columns: string[]
+  unique: boolean
+  primary?: boolean
+  where?: string
+}
+
+export interface Constraint {
+  name: string
+  type: 'unique' | 'check'
+  columns?: string[]
+  definition: string
 }
 
 export interface TableInfo {
+  // Postgres schema or MySQL database; absent for SQLite
+  schema?: string
   name: string
+  type: 'table' | 'view' | 'materialized_view'
+  // Query behind a view
+  definition?: string
   columns: Column[]
+  foreign_keys?: ForeignKey[]
+  indexes?: Index[]
+  constraints?: Constraint[]
   row_count: number
+  // Table comment, or a description stored locally
   description?: string
 }
 
+// from_columns[i] references to_columns[i]; composite keys list several
 export interface TableRelationship {
+  // Constraint name; absent for SQLite
+  name?: string
+  from_schema?: string
   from_table: string
-  from_column: string
+  from_columns: string[]
+  to_schema?: string
   to_table: string
-  to_column: string
+  to_columns: string[]
 }
 
+// qualifiedName returns "schema.table", or just the name without a schema
+export const qualifiedName = (table: { schema?: string, name: string }) =>
+  table.schema ? `${table.schema}.${table.name}` : table.name
+
 export interface SchemaInfo {
   tables: TableInfo[]
   relationships: TableRelationship[]
@@ -46,6 +89,15 @@ export const useSchemaStore = defineStore('schema', {
       this.isLoading = loading
     },
 
+    // replaceTable swaps in a table returned after editing its descriptions
+    replaceTable(table: TableInfo) {
+      if (!this.schema) return
+      const i = this.schema.tables.findIndex(t => qualifiedName(t) === qualifiedName(table))
+      if (i >= 0) {
+        this.schema.tables[i] = table
+      }
+    },
+
     selectTable(tableName: string | null) {
       this.selectedTable = tableName
     },
@@ -62,10 +114,11 @@ export const useSchemaStore = defineStore('schema', {
     relationshipCount: (state) => state.schema?.relationships.length || 0,
     
     getTable: (state) => (tableName: string) => {
-      return state.schema?.tables.find(t => t.name === tableName)
+      return state.schema?.tables.find(t => qualifiedName(t) === tableName)
+        || state.schema?.tables.find(t => t.name === tableName)
     },
 
-    tableNames: (state) => state.schema?.tables.map(t => t.name) || [],
+    tableNames: (state) => state.schema?.tables.map(qualifiedName) || [],
 
     isSchemaLoaded: (state) => state.schema !== null
   }